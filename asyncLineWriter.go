@@ -0,0 +1,238 @@
+package gonl
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AsyncPolicy determines what an AsyncLineWriter does with a
+// completed line when its queue is full.
+type AsyncPolicy int
+
+const (
+	// AsyncBlock causes Write to wait until the queue has room for
+	// the line.
+	AsyncBlock AsyncPolicy = iota
+
+	// AsyncDropNewest drops the line being added to the queue.
+	AsyncDropNewest
+
+	// AsyncDropOldest drops the line at the front of the queue to
+	// make room for the line being added.
+	AsyncDropOldest
+)
+
+// AsyncLineWriter is an io.WriteCloser that decouples the goroutine
+// invoking Write from a potentially slow underlying io.WriteCloser,
+// such as a network socket. Write appends each completed line to a
+// bounded in-memory queue, and a background goroutine writes the
+// queued lines to the underlying io.WriteCloser, combining all lines
+// queued at the time into a single Write call.
+//
+// When the queue is full, the AsyncPolicy determines whether Write
+// waits for room, or drops either the newest or the oldest line. Lines
+// are never partially dropped.
+//
+// Once the underlying io.WriteCloser returns an error, the background
+// goroutine stops writing, and that error is returned by subsequent
+// Write and Close calls.
+//
+// It is important for caller to Close the AsyncLineWriter to flush
+// any residual data that was not terminated with a newline, and to
+// wait for the queued lines to be written.
+type AsyncLineWriter struct {
+	lb     lineBuffer // only accessed by goroutine invoking Write
+	wc     io.WriteCloser
+	closed bool // only accessed by goroutine invoking Write
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	queue    [][]byte
+	max      int
+	policy   AsyncPolicy
+	closing  bool
+	abandon  bool // discard queued lines rather than write them
+	dropped  uint64
+	err      error
+	done     chan struct{}
+}
+
+// NewAsyncLineWriter returns a new AsyncLineWriter that queues up to
+// maxLines completed lines to be written to the provided
+// io.WriteCloser, and starts its background goroutine.
+func NewAsyncLineWriter(wc io.WriteCloser, maxLines int, policy AsyncPolicy) (*AsyncLineWriter, error) {
+	if maxLines <= 0 {
+		return nil, &ConfigError{Type: "AsyncLineWriter", Reason: fmt.Sprintf("when maxLines less than or equal to 0: %d", maxLines)}
+	}
+	if policy < AsyncBlock || policy > AsyncDropOldest {
+		return nil, &ConfigError{Type: "AsyncLineWriter", Reason: fmt.Sprintf("with unknown policy: %d", policy)}
+	}
+	lw := &AsyncLineWriter{
+		wc:     wc,
+		max:    maxLines,
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	lw.notEmpty = sync.NewCond(&lw.mu)
+	lw.notFull = sync.NewCond(&lw.mu)
+	go lw.drain()
+	return lw, nil
+}
+
+// Close queues any residual data that was not terminated with a
+// newline, then waits for the background goroutine to write all
+// queued lines and close the underlying io.WriteCloser.
+func (lw *AsyncLineWriter) Close() error { return lw.CloseTimeout(0) }
+
+// CloseTimeout queues any residual data that was not terminated with
+// a newline, then waits up to timeout for the background goroutine to
+// write all queued lines and close the underlying io.WriteCloser. A
+// timeout less than or equal to 0 waits indefinitely. The residual
+// data is queued even when the queue is full, so CloseTimeout never
+// waits for room in the queue. When the timeout elapses, the lines
+// that have not yet been written are dropped, the underlying
+// io.WriteCloser is closed once any Write in progress returns, and a
+// *CloseTimeoutError reporting the number of dropped lines is
+// returned. Invoking either Close or CloseTimeout more than once has
+// no effect and returns nil.
+func (lw *AsyncLineWriter) CloseTimeout(timeout time.Duration) error {
+	if lw.closed {
+		return nil
+	}
+	lw.closed = true
+
+	lw.mu.Lock()
+	if tail := lw.lb.buffered(); len(tail) > 0 && lw.err == nil {
+		// Append past the queue limit rather than waiting for room,
+		// so the timeout covers every wait.
+		lw.queue = append(lw.queue, append([]byte(nil), tail...))
+	}
+	lw.lb.reset()
+	lw.closing = true
+	lw.notEmpty.Signal()
+	lw.mu.Unlock()
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-lw.done:
+		case <-timer.C:
+			lw.mu.Lock()
+			n := len(lw.queue)
+			lw.abandon = true
+			lw.dropped += uint64(n)
+			lw.queue = nil
+			lw.mu.Unlock()
+			return &CloseTimeoutError{Timeout: timeout, Dropped: n}
+		}
+	}
+
+	<-lw.done
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.err
+}
+
+// Dropped returns the number of lines dropped, either because the
+// queue was full, or because they had not been written before the
+// timeout given to CloseTimeout.
+func (lw *AsyncLineWriter) Dropped() uint64 {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.dropped
+}
+
+// drain runs in its own goroutine, writing queued lines to the
+// underlying io.WriteCloser until the AsyncLineWriter is closed and
+// the queue is empty, then closes the underlying io.WriteCloser.
+func (lw *AsyncLineWriter) drain() {
+	var buf []byte
+
+	lw.mu.Lock()
+	for {
+		for len(lw.queue) == 0 && !lw.closing {
+			lw.notEmpty.Wait()
+		}
+		if len(lw.queue) == 0 || lw.abandon {
+			break // closing and nothing left to write
+		}
+
+		buf = buf[:0]
+		for _, line := range lw.queue {
+			buf = append(buf, line...)
+		}
+		lw.queue = lw.queue[:0]
+		lw.notFull.Broadcast()
+		lw.mu.Unlock()
+
+		_, err := writeAll(lw.wc, buf)
+
+		lw.mu.Lock()
+		if err != nil {
+			lw.err = err
+			lw.notFull.Broadcast() // wake writers waiting for room
+			break
+		}
+	}
+	lw.mu.Unlock()
+
+	err := lw.wc.Close()
+
+	lw.mu.Lock()
+	if lw.err == nil {
+		lw.err = err
+	}
+	lw.mu.Unlock()
+	close(lw.done)
+}
+
+// enqueue appends a copy of line to the queue, applying the policy
+// when the queue is full.
+func (lw *AsyncLineWriter) enqueue(line []byte) error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	if lw.err != nil {
+		return lw.err
+	}
+
+	if len(lw.queue) >= lw.max {
+		switch lw.policy {
+		case AsyncDropNewest:
+			lw.dropped++
+			return nil
+		case AsyncDropOldest:
+			lw.dropped++
+			copy(lw.queue, lw.queue[1:])
+			lw.queue = lw.queue[:len(lw.queue)-1]
+		default:
+			for len(lw.queue) >= lw.max && lw.err == nil {
+				lw.notFull.Wait()
+			}
+			if lw.err != nil {
+				return lw.err
+			}
+		}
+	}
+
+	lw.queue = append(lw.queue, append([]byte(nil), line...))
+	lw.notEmpty.Signal()
+	return nil
+}
+
+// Write buffers p, and appends each completed line to the queue to be
+// written to the underlying io.WriteCloser by the background
+// goroutine. It only waits when the queue is full and the policy is
+// AsyncBlock. It returns ErrClosed after the AsyncLineWriter has been
+// closed.
+func (lw *AsyncLineWriter) Write(p []byte) (int, error) {
+	if lw.closed {
+		return 0, ErrClosed
+	}
+	return lw.lb.write(p, lw.enqueue)
+}
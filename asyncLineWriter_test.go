@@ -0,0 +1,166 @@
+package gonl
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// gatedWriteCloser is an io.WriteCloser whose Write method signals
+// when it has been invoked, then waits until its gate is opened
+// before writing to its buffer.
+type gatedWriteCloser struct {
+	testBuffer
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func newGatedWriteCloser() *gatedWriteCloser {
+	return &gatedWriteCloser{
+		entered: make(chan struct{}, 64),
+		gate:    make(chan struct{}),
+	}
+}
+
+func (g *gatedWriteCloser) Open() { close(g.gate) }
+
+func (g *gatedWriteCloser) Write(p []byte) (int, error) {
+	g.entered <- struct{}{}
+	<-g.gate
+	return g.testBuffer.Write(p)
+}
+
+// newBlockedAsyncLineWriter returns an AsyncLineWriter whose
+// background goroutine is blocked writing "line 1\n" to the returned
+// gatedWriteCloser, so tests may deterministically fill its queue.
+func newBlockedAsyncLineWriter(tb testing.TB, maxLines int, policy AsyncPolicy) (*AsyncLineWriter, *gatedWriteCloser) {
+	tb.Helper()
+	output := newGatedWriteCloser()
+	lw, err := NewAsyncLineWriter(output, maxLines, policy)
+	ensureErrorNil(tb, err)
+	ensureWrite(tb, lw, "line 1\n")
+	<-output.entered
+	return lw, output
+}
+
+func TestAsyncLineWriter(t *testing.T) {
+	t.Run("NewAsyncLineWriter", func(t *testing.T) {
+		_, err := NewAsyncLineWriter(new(discardWriteCloser), 0, AsyncBlock)
+		ensureError(t, err, "maxLines")
+
+		_, err = NewAsyncLineWriter(new(discardWriteCloser), 1, AsyncPolicy(42))
+		ensureError(t, err, "unknown policy")
+	})
+
+	t.Run("writes all lines", func(t *testing.T) {
+		output := new(testBuffer)
+		lw, err := NewAsyncLineWriter(output, 2, AsyncBlock)
+		ensureErrorNil(t, err)
+
+		ensureWrite(t, lw, "line 1\nline 2\nli")
+		ensureWrite(t, lw, "ne 3\nline 4\nline 5")
+		ensureErrorNil(t, lw.Close())
+
+		ensureStringer(t, output, "line 1\nline 2\nline 3\nline 4\nline 5")
+		if got, want := lw.Dropped(), uint64(0); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		lw, output := newBlockedAsyncLineWriter(t, 2, AsyncDropNewest)
+		ensureWrite(t, lw, "line 2\nline 3\nline 4\nline 5\n")
+		output.Open()
+		ensureErrorNil(t, lw.Close())
+
+		ensureStringer(t, output, "line 1\nline 2\nline 3\n")
+		if got, want := lw.Dropped(), uint64(2); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		lw, output := newBlockedAsyncLineWriter(t, 2, AsyncDropOldest)
+		ensureWrite(t, lw, "line 2\nline 3\nline 4\nline 5\n")
+		output.Open()
+		ensureErrorNil(t, lw.Close())
+
+		ensureStringer(t, output, "line 1\nline 4\nline 5\n")
+		if got, want := lw.Dropped(), uint64(2); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		lw, output := newBlockedAsyncLineWriter(t, 2, AsyncBlock)
+
+		returned := make(chan struct{})
+		go func() {
+			ensureWrite(t, lw, "line 2\nline 3\nline 4\n")
+			close(returned)
+		}()
+
+		select {
+		case <-returned:
+			t.Fatal("GOT: Write returned; WANT: Write blocked while queue full")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		output.Open()
+		<-returned
+		ensureErrorNil(t, lw.Close())
+
+		ensureStringer(t, output, "line 1\nline 2\nline 3\nline 4\n")
+		if got, want := lw.Dropped(), uint64(0); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("close timeout", func(t *testing.T) {
+		lw, output := newBlockedAsyncLineWriter(t, 2, AsyncBlock)
+		ensureWrite(t, lw, "line 2\nline 3")
+
+		err := lw.CloseTimeout(time.Millisecond)
+		var te *CloseTimeoutError
+		if !errors.As(err, &te) {
+			t.Fatalf("GOT: %v; WANT: %T", err, te)
+		}
+		if got, want := te.Dropped, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := lw.Dropped(), uint64(2); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		output.Open()
+		<-lw.done
+		ensureStringer(t, output, "line 1\n")
+	})
+
+	t.Run("close timeout with full queue", func(t *testing.T) {
+		lw, output := newBlockedAsyncLineWriter(t, 1, AsyncBlock)
+		ensureWrite(t, lw, "line 2\nline 3")
+
+		returned := make(chan error, 1)
+		go func() { returned <- lw.CloseTimeout(20 * time.Millisecond) }()
+
+		select {
+		case err := <-returned:
+			ensureError(t, err, "dropped 2 lines")
+		case <-time.After(time.Second):
+			t.Fatal("GOT: CloseTimeout blocked; WANT: timeout error")
+		}
+
+		output.Open()
+		<-lw.done
+		ensureStringer(t, output, "line 1\n")
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		lw, err := NewAsyncLineWriter(&errOnWrite{}, 2, AsyncBlock)
+		ensureErrorNil(t, err)
+
+		ensureWrite(t, lw, "line 1\n")
+		ensureError(t, lw.Close(), "test write error")
+	})
+}
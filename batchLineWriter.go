@@ -2,15 +2,20 @@ package gonl
 
 import (
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"io"
+	"sync/atomic"
 )
 
 const maxInt = int(^uint(0) >> 1)
 const minRead = 512
 const smallBufferSize = 64
 
+// copyBufferSize is the size of the buffer used by ReadFrom when it
+// cannot read directly into its own buffer.
+const copyBufferSize = 32 * 1024
+
 // BatchLineWriter is an io.WriteCloser that buffers output to ensure
 // it only emits bytes to the underlying io.WriteCloser on line feed
 // boundaries.
@@ -26,6 +31,11 @@ const smallBufferSize = 64
 // terminated sequence of bytes, potentially with more than one line
 // being written at a time.
 type BatchLineWriter struct {
+	// FinalLine determines what Close does with a final line that is
+	// not newline terminated. The zero value is FinalLineAsIs. It
+	// must be set before Close.
+	FinalLine FinalLinePolicy
+
 	// contents buf[offset:len(buf)]
 	buf []byte
 
@@ -39,6 +49,18 @@ type BatchLineWriter struct {
 
 	// -1 when no newlines in buf
 	indexOfFinalNewline int
+
+	// When greater than 0, the buffer never holds more than this
+	// many bytes, and overflowPolicy determines what happens to a
+	// partial line that would exceed it.
+	maxBufferSize  int
+	overflowPolicy OverflowPolicy
+
+	// true while discarding the remainder of an overflowing line
+	discarding bool
+
+	// activity statistics, also used to report write errors
+	stats counters
 }
 
 // NewBatchLineWriter returns a new BatchLineWriter with the specified
@@ -66,7 +88,7 @@ type BatchLineWriter struct {
 //     }
 func NewBatchLineWriter(wc io.WriteCloser, flushThreshold int) (*BatchLineWriter, error) {
 	if flushThreshold <= 0 {
-		return nil, fmt.Errorf("cannot create BatchLineWriter when flushThreshold less than or equal to 0: %d", flushThreshold)
+		return nil, &ConfigError{Type: "BatchLineWriter", Reason: fmt.Sprintf("when flushThreshold less than or equal to 0: %d", flushThreshold)}
 	}
 	return &BatchLineWriter{
 		wc:                  wc,
@@ -75,11 +97,75 @@ func NewBatchLineWriter(wc io.WriteCloser, flushThreshold int) (*BatchLineWriter
 	}, nil
 }
 
+// OverflowPolicy determines what a BatchLineWriter created by
+// NewBoundedBatchLineWriter does when a partial line would exceed its
+// maximum buffer size.
+type OverflowPolicy int
+
+const (
+	// OverflowFlush writes the buffered partial line to the
+	// underlying io.WriteCloser without a newline, then continues
+	// buffering the remainder of the line.
+	OverflowFlush OverflowPolicy = iota
+
+	// OverflowDiscard discards the buffered partial line, along with
+	// the remainder of the line up to and including its newline.
+	OverflowDiscard
+
+	// OverflowError discards the line like OverflowDiscard, but Write
+	// also returns ErrBufferOverflow along with the number of bytes
+	// from p accepted before the overflow. Writing the remaining bytes
+	// of p continues to discard the overflowing line.
+	OverflowError
+)
+
+// NewBoundedBatchLineWriter returns a new BatchLineWriter like
+// NewBatchLineWriter does, but whose buffer never holds more than
+// maxBufferSize bytes, which must not be less than flushThreshold.
+// Because completed lines are flushed once the buffer reaches the
+// flush threshold, the buffer only fills when a single line without a
+// newline is longer than maxBufferSize, and the specified policy
+// determines what happens to that line. The number of times this
+// happens is reported as Overflows by Stats.
+//
+//     func Example() error {
+//         // Never buffer more than 1 MiB, even when standard input
+//         // has no newlines.
+//         lw, err := gonl.NewBoundedBatchLineWriter(os.Stdout, 4096, 1<<20, gonl.OverflowFlush)
+//         if err != nil {
+//             return err
+//         }
+//         _, rerr := io.Copy(lw, os.Stdin)
+//         cerr := lw.Close()
+//         if rerr == nil {
+//             return cerr
+//         }
+//         return rerr
+//     }
+func NewBoundedBatchLineWriter(wc io.WriteCloser, flushThreshold, maxBufferSize int, policy OverflowPolicy) (*BatchLineWriter, error) {
+	if maxBufferSize < flushThreshold {
+		return nil, &ConfigError{Type: "BatchLineWriter", Reason: fmt.Sprintf("when maxBufferSize less than flushThreshold: %d; %d", maxBufferSize, flushThreshold)}
+	}
+	switch policy {
+	case OverflowFlush, OverflowDiscard, OverflowError:
+	default:
+		return nil, &ConfigError{Type: "BatchLineWriter", Reason: fmt.Sprintf("with unknown overflow policy: %d", policy)}
+	}
+	lw, err := NewBatchLineWriter(wc, flushThreshold)
+	if err != nil {
+		return nil, err
+	}
+	lw.maxBufferSize = maxBufferSize
+	lw.overflowPolicy = policy
+	return lw, nil
+}
+
 // bufferGrow will ensure the backing buffer has enough room to hold
 // at least n more bytes, reslicing the data in the buffer if
 // possible, and expanding the backing array if necessary. It returns
-// the index into the buffer where bytes may be added.
-func (lw *BatchLineWriter) bufferGrow(n int) int {
+// the index into the buffer where bytes may be added, or
+// ErrBufferOverflow when the backing array cannot grow large enough.
+func (lw *BatchLineWriter) bufferGrow(n int) (int, error) {
 	m := lw.bufferLength()
 	if m == 0 && lw.off != 0 {
 		// Reset buffer to reduce likelihood of unnecessary
@@ -89,14 +175,14 @@ func (lw *BatchLineWriter) bufferGrow(n int) int {
 	if i, ok := lw.bufferGrowInline(n); ok {
 		// NOTE: This is the only way to exit this method with lw.off
 		// potentially not being set to 0.
-		return i
+		return i, nil
 	}
 	// NOTE: If we get here, there is no way of leaving this method
 	// without lw.off set to 0, and any used portion of buffer moved
 	// to the left.
 	if lw.buf == nil && n <= smallBufferSize {
 		lw.buf = make([]byte, n, smallBufferSize)
-		return 0
+		return 0, nil
 	}
 	mpn := m + n
 	c := cap(lw.buf)
@@ -106,7 +192,7 @@ func (lw *BatchLineWriter) bufferGrow(n int) int {
 		// byte copying.
 		copy(lw.buf, lw.buf[lw.off:])
 	} else if c > maxInt-c-n {
-		panic(errors.New("gonl.BatchLineWriter: too large"))
+		return 0, ErrBufferOverflow
 	} else {
 		// Allocate new backing array, then copy bytes.
 		buf := make([]byte, 2*c+n)
@@ -116,7 +202,7 @@ func (lw *BatchLineWriter) bufferGrow(n int) int {
 	lw.indexOfFinalNewline -= lw.off
 	lw.off = 0
 	lw.buf = lw.buf[:mpn]
-	return m
+	return m, nil
 }
 
 // bufferGrowInline is an inlineable version of grow for the fast case
@@ -146,16 +232,37 @@ func (lw *BatchLineWriter) bufferReset() {
 }
 
 // Close flushes all buffered data to the underlying io.WriteCloser,
-// including bytes without a trailing LF, then closes the underlying
-// io.WriteCloser. This will either return any error caused by writing
-// the bytes to the underlying io.WriteCloser, or an error caused by
-// closing it. Use this method when done with a BatchLineWriter to
-// prevent data loss.
+// including bytes without a trailing LF, handled according to
+// FinalLine, then closes the underlying io.WriteCloser. This will
+// either return any error caused by writing the bytes to the
+// underlying io.WriteCloser, an error caused by closing it, or an
+// *UnterminatedLineError for the FinalLineError policy. Use this
+// method when done with a BatchLineWriter to prevent data loss.
+// Invoking Close more than once has no effect and returns nil.
 func (lw *BatchLineWriter) Close() error {
 	var err error
 
-	if lw.bufferLength() > 0 {
-		_, err = lw.wc.Write(lw.buf[lw.off:])
+	if lw.wc == nil {
+		return nil // already closed
+	}
+
+	// Completed lines are always written, and only the bytes after
+	// the final newline are subject to the final line policy.
+	completed := lw.off
+	if lw.indexOfFinalNewline >= lw.off {
+		completed = lw.indexOfFinalNewline + 1
+	}
+	midLine := lw.stats.midLine
+	line := atomic.LoadInt64(&lw.stats.lines) + 1
+	if completed > lw.off {
+		midLine = false
+		line += int64(bytes.Count(lw.buf[lw.off:completed], []byte{'\n'}))
+	}
+	tail, ferr := finalLine(lw.FinalLine, lw.buf[completed:], midLine, line)
+
+	if out := append(lw.buf[lw.off:completed], tail...); len(out) > 0 {
+		atomic.AddInt64(&lw.stats.closeFlushes, 1)
+		_, err = lw.stats.writeAll(lw.wc, out)
 		if err != nil {
 			lw.bufferReset()
 			_ = lw.wc.Close()
@@ -165,8 +272,11 @@ func (lw *BatchLineWriter) Close() error {
 	}
 
 	lw.bufferReset()
-	err = lw.wc.Close()
+	err = lw.stats.failed(lw.wc.Close())
 	lw.wc = nil
+	if ferr != nil {
+		return ferr
+	}
 	return err
 }
 
@@ -176,10 +286,8 @@ func (lw *BatchLineWriter) flush(leno, lenp, index int) (int, error) {
 	debug("flush: leno: %d; len(p): %d; index: %d\n", leno, lenp, index)
 	debug("flush: lw.off: %d; expected nw: %d\n", lw.off, index-lw.off)
 	debug("flush: before: %q\n", lw.buf[lw.off:])
-	nw, err := lw.wc.Write(lw.buf[lw.off:index])
-	if nw < 0 {
-		return nw, errors.New("invalid write result")
-	}
+	atomic.AddInt64(&lw.stats.flushes, 1)
+	nw, err := lw.stats.writeAll(lw.wc, lw.buf[lw.off:index])
 	if err == nil {
 		lw.off += nw                // advance offset to after nw
 		lw.indexOfFinalNewline = -1 // optimization
@@ -214,6 +322,16 @@ func (lw *BatchLineWriter) flush(leno, lenp, index int) (int, error) {
 	return 0, err
 }
 
+// flushLines flushes all completed lines in the buffer to the
+// underlying io.WriteCloser, regardless of the threshold size.
+func (lw *BatchLineWriter) flushLines() error {
+	if lw.indexOfFinalNewline < lw.off {
+		return nil
+	}
+	_, err := lw.flush(lw.bufferLength(), 0, lw.indexOfFinalNewline+1)
+	return err
+}
+
 // ReadFrom reads data from r until io.EOF or error, periodically
 // flushing one or more completed newlines to the underlying
 // io.WriteCloser when the buffer length exceeds the configured
@@ -226,19 +344,49 @@ func (lw *BatchLineWriter) flush(leno, lenp, index int) (int, error) {
 // to copy bytes from the io.Reader, through two buffers, and finally
 // to the io.Writer.
 func (lw *BatchLineWriter) ReadFrom(r io.Reader) (int64, error) {
+	return lw.ReadFromContext(context.Background(), r)
+}
+
+// ReadFromContext is like ReadFrom, but checks whether ctx is done
+// before each read from r. When ctx is done, it flushes all completed
+// lines in the buffer to the underlying io.WriteCloser, regardless of
+// the threshold size, then returns the number of bytes read from r
+// along with ctx.Err(). Any bytes after the final newline remain
+// buffered. This allows long running copies from pipes and sockets to
+// be stopped cleanly, provided the Read calls on r eventually return.
+func (lw *BatchLineWriter) ReadFromContext(ctx context.Context, r io.Reader) (int64, error) {
 	var totalRead int64
 
+	if lw.wc == nil {
+		return 0, ErrClosed
+	}
+	if lw.maxBufferSize > 0 {
+		return lw.readFromBounded(ctx, r)
+	}
+
 	for {
+		if err := ctx.Err(); err != nil {
+			if werr := lw.flushLines(); werr != nil {
+				return totalRead, werr
+			}
+			return totalRead, err
+		}
+
 		leno := lw.bufferLength()
-		m := lw.bufferGrow(minRead)
+		m, err := lw.bufferGrow(minRead)
+		if err != nil {
+			return totalRead, err
+		}
 		lw.buf = lw.buf[:m]
 
 		nr, rerr := r.Read(lw.buf[m:cap(lw.buf)])
-		if nr < 0 {
-			return totalRead, errors.New("invalid read result")
+		if nr < 0 || nr > cap(lw.buf)-m {
+			return totalRead, ErrInvalidRead
 		}
 
 		lw.buf = lw.buf[:m+nr]
+		atomic.AddInt64(&lw.stats.bytesIn, int64(nr))
+		lw.stats.buffered(lw.bufferLength())
 
 		// NEWLINE LOGIC
 
@@ -272,19 +420,140 @@ func (lw *BatchLineWriter) ReadFrom(r io.Reader) (int64, error) {
 	}
 }
 
+// readFromBounded is like ReadFromContext, but reads into a separate
+// buffer, and writes what it reads with writeBounded, so the buffer
+// never holds more than maxBufferSize bytes.
+func (lw *BatchLineWriter) readFromBounded(ctx context.Context, r io.Reader) (int64, error) {
+	var totalRead int64
+
+	size := lw.maxBufferSize
+	if size > copyBufferSize {
+		size = copyBufferSize
+	}
+	buf := make([]byte, size)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			if werr := lw.flushLines(); werr != nil {
+				return totalRead, werr
+			}
+			return totalRead, err
+		}
+
+		nr, rerr := r.Read(buf)
+		if nr < 0 || nr > len(buf) {
+			return totalRead, ErrInvalidRead
+		}
+		totalRead += int64(nr)
+
+		if _, werr := lw.writeBounded(buf[:nr]); werr != nil {
+			return totalRead, werr
+		}
+
+		if rerr == io.EOF {
+			return totalRead, nil
+		}
+		if rerr != nil {
+			return totalRead, rerr
+		}
+	}
+}
+
 // Write appends bytes from p to the internal buffer, flushing buffer
 // up to and including the final LF when buffer length exceeds
-// threshold specified when creating the BatchLineWriter.
+// threshold specified when creating the BatchLineWriter. When created
+// by NewBoundedBatchLineWriter, a line that would not fit in the buffer
+// is handled according to its OverflowPolicy. It returns ErrClosed
+// after the BatchLineWriter has been closed.
 func (lw *BatchLineWriter) Write(p []byte) (int, error) {
+	if lw.wc == nil {
+		return 0, ErrClosed
+	}
+	if lw.maxBufferSize > 0 {
+		return lw.writeBounded(p)
+	}
+	return lw.write(p)
+}
+
+// writeBounded writes p in pieces that fit in the buffer without
+// exceeding maxBufferSize, handling a line that would exceed it
+// according to the overflow policy.
+func (lw *BatchLineWriter) writeBounded(p []byte) (int, error) {
+	var nw int
+
+	for len(p) > 0 {
+		if lw.discarding {
+			i := bytes.IndexByte(p, '\n')
+			if i == -1 {
+				atomic.AddInt64(&lw.stats.bytesIn, int64(len(p)))
+				return nw + len(p), nil
+			}
+			i++ // also discard the newline
+			atomic.AddInt64(&lw.stats.bytesIn, int64(i))
+			nw += i
+			p = p[i:]
+			lw.discarding = false
+			continue
+		}
+
+		room := lw.maxBufferSize - lw.bufferLength()
+		if room > 0 {
+			if room > len(p) {
+				room = len(p)
+			}
+			n, err := lw.write(p[:room])
+			nw += n
+			if err != nil {
+				return nw, err
+			}
+			p = p[room:]
+			continue
+		}
+
+		if lw.indexOfFinalNewline >= lw.off {
+			// A previous write error left completed lines in the
+			// buffer.
+			if err := lw.flushLines(); err != nil {
+				return nw, err
+			}
+			continue
+		}
+
+		// Buffer is full with a single partial line.
+		atomic.AddInt64(&lw.stats.overflows, 1)
+		if lw.overflowPolicy == OverflowFlush {
+			if _, err := lw.flush(lw.bufferLength(), 0, len(lw.buf)); err != nil {
+				return nw, err
+			}
+			continue
+		}
+		lw.bufferReset()
+		lw.discarding = true
+		if lw.overflowPolicy == OverflowError {
+			return nw, ErrBufferOverflow
+		}
+	}
+
+	return nw, nil
+}
+
+// write appends bytes from p to the internal buffer, flushing buffer
+// up to and including the final LF when buffer length exceeds the
+// threshold.
+func (lw *BatchLineWriter) write(p []byte) (int, error) {
 	leno := lw.bufferLength()
 
 	// functionally equivalent to `lw.buf = append(lw.buf, p...)`
 	m, ok := lw.bufferGrowInline(len(p))
 	if !ok {
-		m = lw.bufferGrow(len(p))
+		var err error
+		if m, err = lw.bufferGrow(len(p)); err != nil {
+			return 0, err
+		}
 	}
 	// Because just grew, no way this does not copy all p.
 	copy(lw.buf[m:], p)
+	lw.stats.buffered(lw.bufferLength())
 
 	if finalIndex := bytes.LastIndexByte(p, '\n'); finalIndex >= 0 {
 		lw.indexOfFinalNewline = m + finalIndex
@@ -297,10 +566,18 @@ func (lw *BatchLineWriter) Write(p []byte) (int, error) {
 	if lw.bufferLength() < lw.flushThreshold || lw.indexOfFinalNewline < lw.off {
 		// Either do not need to flush, or no newline exists in buffer
 		debug("Write: no need to flush\n")
+		atomic.AddInt64(&lw.stats.bytesIn, int64(len(p)))
 		return len(p), nil
 	}
 
 	// Buffer is larger than threshold, and has LF: write everything
 	// up to and including that final LF.
-	return lw.flush(leno, len(p), lw.indexOfFinalNewline+1)
+	nw, err := lw.flush(leno, len(p), lw.indexOfFinalNewline+1)
+	atomic.AddInt64(&lw.stats.bytesIn, int64(nw))
+	return nw, err
 }
+
+// Stats returns a snapshot of the activity of the BatchLineWriter
+// since it was created. It is safe to invoke from any goroutine, even
+// while another goroutine is writing to the BatchLineWriter.
+func (lw *BatchLineWriter) Stats() LineWriterStats { return lw.stats.snapshot() }
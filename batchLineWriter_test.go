@@ -2,8 +2,10 @@ package gonl
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 )
 
@@ -34,7 +36,9 @@ func (lw *BatchLineWriter) bufferString() string { return string(lw.buf[lw.off:]
 func (lw *BatchLineWriter) bufferWrite(p []byte) (n int, err error) {
 	m, ok := lw.bufferGrowInline(len(p))
 	if !ok {
-		m = lw.bufferGrow(len(p))
+		if m, err = lw.bufferGrow(len(p)); err != nil {
+			return 0, err
+		}
 	}
 	return copy(lw.buf[m:], p), nil
 }
@@ -111,6 +115,38 @@ func TestBatchLineWriter(t *testing.T) {
 			ensureErrorNil(t, err)
 			ensureError(t, wc.Close())
 		})
+		t.Run("short write", func(t *testing.T) {
+			output := new(testBuffer)
+			wc, err := NewBatchLineWriter(NopCloseWriter(trickleWriter{Writer: output, max: 3}), 16)
+			ensureErrorNil(t, err)
+			ensureWrite(t, wc, "line 1\nline 2")
+			ensureErrorNil(t, wc.Close())
+			ensureStringer(t, output, "line 1\nline 2")
+		})
+		t.Run("short write during flush", func(t *testing.T) {
+			output := new(testBuffer)
+			wc, err := NewBatchLineWriter(NopCloseWriter(trickleWriter{Writer: output, max: 3}), 8)
+			ensureErrorNil(t, err)
+			ensureWrite(t, wc, "line 1\nline 2\nline 3")
+			ensureStringer(t, output, "line 1\nline 2\n")
+			ensureErrorNil(t, wc.Close())
+			ensureStringer(t, output, "line 1\nline 2\nline 3")
+		})
+		t.Run("short write with error", func(t *testing.T) {
+			output := new(testBuffer)
+			wc, err := NewBatchLineWriter(NopCloseWriter(ShortWriter(output, 4)), 16)
+			ensureErrorNil(t, err)
+			ensureWrite(t, wc, "line 1\nline 2")
+			ensureError(t, wc.Close(), io.ErrShortWrite.Error())
+			ensureStringer(t, output, "line")
+		})
+		t.Run("short write without progress", func(t *testing.T) {
+			output := new(testBuffer)
+			wc, err := NewBatchLineWriter(NopCloseWriter(trickleWriter{Writer: output, max: 0}), 16)
+			ensureErrorNil(t, err)
+			ensureWrite(t, wc, "line 1")
+			ensureError(t, wc.Close(), io.ErrShortWrite.Error())
+		})
 	})
 
 	t.Run("flushCompleted", func(t *testing.T) {
@@ -168,6 +204,56 @@ func TestBatchLineWriter(t *testing.T) {
 		ensureStringer(t, output, "line 1\nline 2\nline 3\nline 4\nline 5")
 	})
 
+	t.Run("ReadFromContext", func(t *testing.T) {
+		t.Run("canceled before read", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			lw, err := NewBatchLineWriter(new(testBuffer), 1024)
+			ensureErrorNil(t, err)
+
+			// testReader panics when read from.
+			nr, err := lw.ReadFromContext(ctx, &testReader{})
+			if got, want := err, context.Canceled; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := nr, int64(0); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("canceled between reads", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			r := &cancelingReader{
+				Reader: &testReader{tuples: []tuple{
+					tuple{"line 1\n", nil},
+					tuple{"line 2\nline 3", nil},
+				}},
+				cancel: cancel,
+				reads:  2,
+			}
+
+			output := new(testBuffer)
+			lw, err := NewBatchLineWriter(output, 1024)
+			ensureErrorNil(t, err)
+
+			nr, err := lw.ReadFromContext(ctx, r)
+			if got, want := err, context.Canceled; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := nr, int64(20); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			// Completed lines flushed even though below threshold.
+			ensureStringer(t, output, "line 1\nline 2\n")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "line 1\nline 2\nline 3")
+		})
+	})
+
 	t.Run("Write", func(t *testing.T) {
 		t.Run("buf empty | data no newline | no flush", func(t *testing.T) {
 			output := new(testBuffer)
@@ -1426,6 +1512,88 @@ func TestBatchLineWriter(t *testing.T) {
 		})
 	})
 
+	t.Run("bounded", func(t *testing.T) {
+		t.Run("NewBoundedBatchLineWriter", func(t *testing.T) {
+			_, err := NewBoundedBatchLineWriter(new(discardWriteCloser), 16, 8, OverflowFlush)
+			ensureError(t, err, "maxBufferSize less than flushThreshold")
+
+			_, err = NewBoundedBatchLineWriter(new(discardWriteCloser), 16, 32, OverflowPolicy(42))
+			ensureError(t, err, "unknown overflow policy")
+		})
+
+		t.Run("flush", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewBoundedBatchLineWriter(output, 4, 8, OverflowFlush)
+			ensureErrorNil(t, err)
+
+			ensureWrite(t, lw, "abcdefghijkl\nxy\n")
+			ensureStringer(t, output, "abcdefghijkl\nxy\n")
+			ensureErrorNil(t, lw.Close())
+
+			stats := lw.Stats()
+			if got, want := stats.Overflows, int64(1); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := stats.PeakBuffer, int64(8); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("discard", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewBoundedBatchLineWriter(output, 4, 8, OverflowDiscard)
+			ensureErrorNil(t, err)
+
+			ensureWrite(t, lw, "abcdefgh")
+			ensureWrite(t, lw, "ij")
+			ensureWrite(t, lw, "kl\nxy\n")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "xy\n")
+
+			if got, want := lw.Stats().Overflows, int64(1); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("error", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewBoundedBatchLineWriter(output, 4, 8, OverflowError)
+			ensureErrorNil(t, err)
+
+			p := []byte("abcdefghijkl\nxy\n")
+			n, err := lw.Write(p)
+			if got, want := err, ErrBufferOverflow; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := n, 8; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			// Remainder of overflowing line is discarded.
+			ensureWrite(t, lw, string(p[n:]))
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "xy\n")
+		})
+
+		t.Run("ReadFrom", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewBoundedBatchLineWriter(output, 4, 16, OverflowDiscard)
+			ensureErrorNil(t, err)
+
+			nr, err := lw.ReadFrom(strings.NewReader(strings.Repeat("a", 100) + "\nb\nc"))
+			ensureErrorNil(t, err)
+			if got, want := nr, int64(104); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "b\nc")
+
+			if got, want := lw.Stats().PeakBuffer, int64(16); got > want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
 	t.Run("digest", func(t *testing.T) {
 		// ??? not really worried about true message authentication
 		// codes. Just want to shove data into an io.Writer that does a
@@ -0,0 +1,179 @@
+package gonl
+
+import (
+	"errors"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// BOMReader is an io.Reader that detects and removes a byte order mark
+// at the start of the source io.Reader, and transcodes UTF-16LE and
+// UTF-16BE to UTF-8, so NewlineCounter and the line writers see UTF-8
+// with single byte newlines. Input that does not begin with a UTF-16
+// byte order mark is returned as-is, less any UTF-8 byte order mark.
+//
+// Invalid UTF-16, such as an unpaired surrogate or an odd number of
+// bytes, is transcoded to the Unicode replacement character, U+FFFD.
+// Windows line endings remain CRLF, and may be converted with a
+// LineEndingReader.
+//
+//     r, err := gonl.NewLineEndingReader(gonl.NewBOMReader(f), gonl.CRLFToLF)
+//     if err != nil {
+//         return err
+//     }
+//     lines, err := gonl.NewlineCounter(r)
+type BOMReader struct {
+	tr       transformReader
+	sniffed  bool
+	utf16    bool
+	bigEnd   bool
+	odd      []byte // byte of incomplete UTF-16 code unit
+	high     rune   // high surrogate awaiting its low surrogate
+	encoding string
+}
+
+// NewBOMReader returns a new BOMReader that reads from r.
+func NewBOMReader(r io.Reader) *BOMReader {
+	br := &BOMReader{odd: make([]byte, 0, 1)}
+	br.tr = transformReader{r: r, transform: br.decode, flush: br.flush}
+	return br
+}
+
+// Encoding returns "UTF-8", "UTF-16LE", or "UTF-16BE" according to the
+// byte order mark found, or the empty string when there was none, or
+// Read has not yet been invoked.
+func (r *BOMReader) Encoding() string { return r.encoding }
+
+// Read reads up to len(p) bytes of UTF-8 into p. It returns the number
+// of bytes read (0 <= n <= len(p)) and any error encountered. The
+// error from the source io.Reader is returned after all bytes read
+// prior to it have been returned.
+func (r *BOMReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !r.sniffed {
+		if err := r.sniff(); err != nil {
+			return 0, err
+		}
+	}
+	if !r.utf16 && len(r.tr.out) == 0 && r.tr.err == nil {
+		// Once the bytes read while sniffing have been returned,
+		// input without a UTF-16 byte order mark is read directly.
+		return r.tr.r.Read(p)
+	}
+	return r.tr.Read(p)
+}
+
+// sniff reads enough bytes to detect a byte order mark, and prepares
+// the bytes following it to be returned.
+func (r *BOMReader) sniff() error {
+	r.sniffed = true
+	buf := make([]byte, 4096)
+
+	var n int
+	var err error
+	for n < 3 && err == nil {
+		var nr int
+		nr, err = r.tr.r.Read(buf[n:])
+		if nr < 0 || nr > len(buf)-n {
+			return ErrInvalidRead
+		}
+		n += nr
+	}
+	p := buf[:n]
+
+	switch {
+	case len(p) >= 3 && p[0] == 0xEF && p[1] == 0xBB && p[2] == 0xBF:
+		r.encoding = "UTF-8"
+		p = p[3:]
+	case len(p) >= 2 && p[0] == 0xFF && p[1] == 0xFE:
+		r.encoding = "UTF-16LE"
+		r.utf16 = true
+		p = p[2:]
+	case len(p) >= 2 && p[0] == 0xFE && p[1] == 0xFF:
+		r.encoding = "UTF-16BE"
+		r.utf16, r.bigEnd = true, true
+		p = p[2:]
+	}
+
+	if r.utf16 {
+		// Transcoded bytes are appended to a new slice, so the sniff
+		// buffer may be used for subsequent reads.
+		r.tr.in = buf
+		p = r.decode(nil, p)
+		if errors.Is(err, io.EOF) {
+			p = r.flush(p)
+		}
+	}
+	r.tr.out = p
+	r.tr.err = err
+	return nil
+}
+
+// decode appends the UTF-8 transcoding of the UTF-16 bytes in src to
+// dst, holding an incomplete code unit or a high surrogate at the end
+// of src until the following bytes are known.
+func (r *BOMReader) decode(dst, src []byte) []byte {
+	if len(r.odd) == 1 && len(src) > 0 {
+		dst = r.appendUnit(dst, r.odd[0], src[0])
+		r.odd = r.odd[:0]
+		src = src[1:]
+	}
+	for ; len(src) >= 2; src = src[2:] {
+		dst = r.appendUnit(dst, src[0], src[1])
+	}
+	if len(src) == 1 {
+		r.odd = append(r.odd, src[0])
+	}
+	return dst
+}
+
+// flush appends U+FFFD to dst for an unpaired high surrogate or an
+// incomplete code unit held at the end of the stream.
+func (r *BOMReader) flush(dst []byte) []byte {
+	if r.high != 0 {
+		dst = appendRune(dst, utf8.RuneError)
+		r.high = 0
+	}
+	if len(r.odd) == 1 {
+		dst = appendRune(dst, utf8.RuneError)
+		r.odd = r.odd[:0]
+	}
+	return dst
+}
+
+// appendUnit appends the UTF-8 encoding of the UTF-16 code unit in
+// bytes a and b to dst.
+func (r *BOMReader) appendUnit(dst []byte, a, b byte) []byte {
+	u := rune(a) | rune(b)<<8
+	if r.bigEnd {
+		u = rune(a)<<8 | rune(b)
+	}
+
+	if r.high != 0 {
+		high := r.high
+		r.high = 0
+		if utf16.IsSurrogate(u) && u >= 0xDC00 {
+			return appendRune(dst, utf16.DecodeRune(high, u))
+		}
+		dst = appendRune(dst, utf8.RuneError) // unpaired high
+	}
+
+	switch {
+	case u >= 0xD800 && u < 0xDC00:
+		r.high = u
+		return dst
+	case utf16.IsSurrogate(u):
+		return appendRune(dst, utf8.RuneError) // unpaired low
+	default:
+		return appendRune(dst, u)
+	}
+}
+
+// appendRune appends the UTF-8 encoding of r to dst.
+func appendRune(dst []byte, r rune) []byte {
+	var b [utf8.UTFMax]byte
+	return append(dst, b[:utf8.EncodeRune(b[:], r)]...)
+}
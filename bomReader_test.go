@@ -0,0 +1,74 @@
+package gonl
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestBOMReader(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		want     string
+		encoding string
+	}{
+		{"empty", "", "", ""},
+		{"no BOM", "one\ntwo\n", "one\ntwo\n", ""},
+		{"short", "a", "a", ""},
+		{"UTF-8", "\xef\xbb\xbfone\n€\n", "one\n€\n", "UTF-8"},
+		{"UTF-16LE", "\xff\xfeo\x00n\x00e\x00\n\x00\xac\x20\n\x00", "one\n€\n", "UTF-16LE"},
+		{"UTF-16BE", "\xfe\xff\x00o\x00n\x00e\x00\n\x20\xac\x00\n", "one\n€\n", "UTF-16BE"},
+		{"surrogate pair", "\xff\xfe\x3d\xd8\x00\xde\n\x00", "😀\n", "UTF-16LE"},
+		{"unpaired high surrogate", "\xff\xfe\x3d\xd8a\x00", "�a", "UTF-16LE"},
+		{"unpaired low surrogate", "\xff\xfe\x00\xdea\x00", "�a", "UTF-16LE"},
+		{"high surrogate at end", "\xff\xfea\x00\x3d\xd8", "a�", "UTF-16LE"},
+		{"odd byte at end", "\xff\xfea\x00b", "a�", "UTF-16LE"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := NewBOMReader(strings.NewReader(c.input))
+			buf, err := ioutil.ReadAll(r)
+			ensureErrorNil(t, err)
+			if got, want := string(buf), c.want; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			if got, want := r.Encoding(), c.encoding; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		})
+		t.Run(c.name+" one byte reads", func(t *testing.T) {
+			r := NewBOMReader(iotest.OneByteReader(strings.NewReader(c.input)))
+			buf, err := ioutil.ReadAll(iotest.OneByteReader(r))
+			ensureErrorNil(t, err)
+			if got, want := string(buf), c.want; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		})
+	}
+
+	t.Run("read error", func(t *testing.T) {
+		errRead := errors.New("read error")
+		r := NewBOMReader(&testReader{tuples: []tuple{
+			tuple{"\xff\xfea\x00\n\x00", errRead},
+		}})
+		buf, err := ioutil.ReadAll(r)
+		if got, want := err, errRead; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := string(buf), "a\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("NewlineCounter", func(t *testing.T) {
+		lines, err := NewlineCounter(NewBOMReader(strings.NewReader("\xff\xfea\x00\n\x00b\x00\n\x00")))
+		ensureErrorNil(t, err)
+		if got, want := lines, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,18 @@
+package gonl
+
+import "time"
+
+// Clock provides the current time and the ability to pause the
+// calling goroutine. Structures in this library that depend on the
+// passage of time accept a Clock, so they may be tested
+// deterministically.
+type Clock interface {
+	Now() time.Time
+	Sleep(time.Duration)
+}
+
+// systemClock is the Clock used when a structure is not provided one.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
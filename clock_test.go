@@ -0,0 +1,25 @@
+package gonl
+
+import "time"
+
+// testClock is a Clock whose time only advances when it is told to
+// sleep, or when the test explicitly advances it.
+type testClock struct {
+	now    time.Time
+	slept  time.Duration
+	sleeps int
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) Sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+	c.slept += d
+	c.sleeps++
+}
@@ -0,0 +1,150 @@
+package gonl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// LineEnding is a style of line terminator.
+type LineEnding int
+
+const (
+	// LineEndingNone means no line terminators were found.
+	LineEndingNone LineEnding = iota
+
+	// LineEndingLF is a LF that is not preceded by CR.
+	LineEndingLF
+
+	// LineEndingCRLF is a CR followed by LF.
+	LineEndingCRLF
+
+	// LineEndingCR is a CR that is not followed by LF.
+	LineEndingCR
+)
+
+func (le LineEnding) String() string {
+	switch le {
+	case LineEndingNone:
+		return "none"
+	case LineEndingLF:
+		return "LF"
+	case LineEndingCRLF:
+		return "CRLF"
+	case LineEndingCR:
+		return "CR"
+	default:
+		return "unknown"
+	}
+}
+
+// LineEndings reports the line terminators found by
+// DetectLineEndings.
+type LineEndings struct {
+	// LF, CRLF, and CR are the number of each style of terminator.
+	LF, CRLF, CR int
+
+	// Dominant is the most common style of terminator, preferring
+	// LF, then CRLF, then CR when counts are equal, or LineEndingNone
+	// when there are no terminators.
+	Dominant LineEnding
+
+	// Mixed is true when more than one style of terminator was found.
+	Mixed bool
+
+	// Unterminated is true when the final line does not end with a
+	// terminator.
+	Unterminated bool
+}
+
+// DetectLineEndings reads from the io.Reader until it receives a read
+// error, such as io.EOF, and reports the line terminators it read. A
+// CR at the end of one read followed by a LF at the start of the next
+// is counted as a single CRLF. Like NewlineCounter, it does not
+// return io.EOF, but returns any other read error along with what it
+// found before the error.
+func DetectLineEndings(r io.Reader) (LineEndings, error) {
+	buf := make([]byte, 4096)
+	var le LineEndings
+	var err error
+	var n int
+	var pendingCR, any bool
+	var final byte
+
+	for {
+		n, err = r.Read(buf)
+		if n > 0 {
+			any = true
+			final = buf[n-1]
+			chunk := buf[:n]
+			if pendingCR {
+				pendingCR = false
+				if chunk[0] == '\n' {
+					le.CRLF++
+					chunk = chunk[1:]
+				} else {
+					le.CR++
+				}
+			}
+			for {
+				index := bytes.IndexAny(chunk, "\r\n")
+				if index == -1 {
+					break // done counting terminators from this chunk
+				}
+				if chunk[index] == '\n' {
+					le.LF++
+				} else if index+1 == len(chunk) {
+					pendingCR = true // need next chunk to know
+				} else if chunk[index+1] == '\n' {
+					le.CRLF++
+					index++
+				} else {
+					le.CR++
+				}
+				chunk = chunk[index+1:]
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil // io.EOF is expected at end of stream
+			}
+			break // do not try to read more if error
+		}
+	}
+
+	if pendingCR {
+		le.CR++ // stream ended with CR
+	}
+	le.Unterminated = any && final != '\n' && final != '\r'
+
+	var styles int
+	for _, c := range []struct {
+		count int
+		style LineEnding
+	}{{le.LF, LineEndingLF}, {le.CRLF, LineEndingCRLF}, {le.CR, LineEndingCR}} {
+		if c.count == 0 {
+			continue
+		}
+		styles++
+		if le.Dominant == LineEndingNone || c.count > le.count(le.Dominant) {
+			le.Dominant = c.style
+		}
+	}
+	le.Mixed = styles > 1
+
+	return le, err
+}
+
+// count returns the number of terminators of the specified style.
+func (le LineEndings) count(style LineEnding) int {
+	switch style {
+	case LineEndingLF:
+		return le.LF
+	case LineEndingCRLF:
+		return le.CRLF
+	case LineEndingCR:
+		return le.CR
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,61 @@
+package gonl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDetectLineEndings(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  LineEndings
+	}{
+		{"empty", "", LineEndings{}},
+		{"unterminated", "abc", LineEndings{Unterminated: true}},
+		{"LF", "a\nb\n", LineEndings{LF: 2, Dominant: LineEndingLF}},
+		{"CRLF", "a\r\nb\r\nc", LineEndings{CRLF: 2, Dominant: LineEndingCRLF, Unterminated: true}},
+		{"CR", "a\rb\r", LineEndings{CR: 2, Dominant: LineEndingCR}},
+		{"mixed", "a\r\nb\nc\r\nd\r", LineEndings{LF: 1, CRLF: 2, CR: 1, Dominant: LineEndingCRLF, Mixed: true}},
+		{"tie prefers LF", "a\r\nb\n", LineEndings{LF: 1, CRLF: 1, Dominant: LineEndingLF, Mixed: true}},
+		{"blank lines", "\r\n\r\n\n\r\r", LineEndings{LF: 1, CRLF: 2, CR: 2, Dominant: LineEndingCRLF, Mixed: true}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := DetectLineEndings(strings.NewReader(c.input))
+			ensureErrorNil(t, err)
+			if got != c.want {
+				t.Errorf("GOT: %+v; WANT: %+v", got, c.want)
+			}
+		})
+		t.Run(c.name+" one byte reads", func(t *testing.T) {
+			got, err := DetectLineEndings(iotest.OneByteReader(strings.NewReader(c.input)))
+			ensureErrorNil(t, err)
+			if got != c.want {
+				t.Errorf("GOT: %+v; WANT: %+v", got, c.want)
+			}
+		})
+	}
+
+	t.Run("read error", func(t *testing.T) {
+		errRead := errors.New("read error")
+		got, err := DetectLineEndings(&testReader{tuples: []tuple{
+			tuple{"a\nb", errRead},
+		}})
+		if got, want := err, errRead; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := got.LF, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("String", func(t *testing.T) {
+		if got, want := LineEndingCRLF.String(), "CRLF"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package gonl
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrClosed is returned when writing to, or reading into, a structure
+// from this library after it has been closed.
+var ErrClosed = errors.New("gonl: closed")
+
+// ErrInvalidRead is returned when an io.Reader returns a negative
+// count or a count larger than the provided buffer.
+var ErrInvalidRead = errors.New("gonl: invalid read result")
+
+// ErrInvalidWrite is returned when an io.Writer returns a negative
+// count or a count larger than the provided buffer.
+var ErrInvalidWrite = errors.New("gonl: invalid write result")
+
+// ErrBufferOverflow is returned when a buffer would need to grow
+// larger than it is allowed to.
+var ErrBufferOverflow = errors.New("gonl: buffer overflow")
+
+// ErrInvalidConfig is matched by errors.Is for each ConfigError.
+var ErrInvalidConfig = errors.New("gonl: invalid configuration")
+
+// ConfigError is returned by constructors when invoked with an
+// invalid configuration.
+type ConfigError struct {
+	// Type is the name of the structure that could not be created.
+	Type string
+
+	// Reason describes the invalid configuration.
+	Reason string
+
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	s := "cannot create " + e.Type
+	if e.Reason != "" {
+		s += " " + e.Reason
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// Is returns true when target is ErrInvalidConfig.
+func (e *ConfigError) Is(target error) bool { return target == ErrInvalidConfig }
+
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// WriteError is returned when writing to an underlying io.Writer
+// fails, and records where in the output stream the failure happened.
+type WriteError struct {
+	// Offset is the number of bytes successfully written to the
+	// underlying io.Writer before the failure, which is also the byte
+	// offset of the first byte that was not written.
+	Offset int64
+
+	// Line is the line number, starting at 1, of the line holding the
+	// first byte that was not written.
+	Line int64
+
+	// Err is the error returned by the underlying io.Writer.
+	Err error
+}
+
+func (e *WriteError) Error() string {
+	return "cannot write at offset " + strconv.FormatInt(e.Offset, 10) + ", line " + strconv.FormatInt(e.Line, 10) + ": " + e.Err.Error()
+}
+
+func (e *WriteError) Unwrap() error { return e.Err }
+
+// CloseTimeoutError is returned by AsyncLineWriter.CloseTimeout when
+// the queued lines are not written before the timeout elapses.
+type CloseTimeoutError struct {
+	// Timeout is the timeout given to CloseTimeout.
+	Timeout time.Duration
+
+	// Dropped is the number of queued lines that were dropped.
+	Dropped int
+}
+
+func (e *CloseTimeoutError) Error() string {
+	return "cannot write queued lines within " + e.Timeout.String() + ": dropped " + strconv.Itoa(e.Dropped) + " lines"
+}
+
+// InvalidUTF8Error is returned by a UTF8LineWriter using the UTF8Error
+// policy when a line is not valid UTF-8.
+type InvalidUTF8Error struct {
+	// Offset is the byte offset, in the stream written to the
+	// UTF8LineWriter, of the first byte of the invalid sequence.
+	Offset int64
+
+	// Line is the line number, starting at 1, of the invalid line.
+	Line int64
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return "invalid UTF-8 at offset " + strconv.FormatInt(e.Offset, 10) + ", line " + strconv.FormatInt(e.Line, 10)
+}
+
+// UnterminatedLineError is returned by Close of a BatchLineWriter or
+// PerLineWriter using the FinalLineError policy when the final line
+// is not newline terminated.
+type UnterminatedLineError struct {
+	// Line is the line number, starting at 1, of the unterminated
+	// final line.
+	Line int64
+
+	// Length is the number of bytes of the final line that remained
+	// buffered, and were discarded.
+	Length int
+}
+
+func (e *UnterminatedLineError) Error() string {
+	return "unterminated final line " + strconv.FormatInt(e.Line, 10) + " of " + strconv.Itoa(e.Length) + " bytes"
+}
@@ -0,0 +1,259 @@
+package gonl
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrClosed(t *testing.T) {
+	constructors := []struct {
+		name string
+		new  func(testing.TB, io.WriteCloser) io.WriteCloser
+	}{
+		{"AsyncLineWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			lw, err := NewAsyncLineWriter(wc, 16, AsyncBlock)
+			ensureErrorNil(tb, err)
+			return lw
+		}},
+		{"BatchLineWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			lw, err := NewBatchLineWriter(wc, 16)
+			ensureErrorNil(tb, err)
+			return lw
+		}},
+		{"GzipMemberWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			gw, err := NewGzipMemberWriter(wc, gzip.BestSpeed)
+			ensureErrorNil(tb, err)
+			return gw
+		}},
+		{"LineEndingWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			w, err := NewLineEndingWriter(wc, LFToCRLF)
+			ensureErrorNil(tb, err)
+			return w
+		}},
+		{"NumberLineWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			return NewNumberLineWriter(wc)
+		}},
+		{"PerLineWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			return NewPerLineWriter(wc)
+		}},
+		{"RateLimitedLineWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			lw, err := NewRateLimitedLineWriter(wc, 100, 0)
+			ensureErrorNil(tb, err)
+			return lw
+		}},
+		{"RetryWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			rw, err := NewRetryWriter(wc, 3, time.Millisecond, time.Millisecond)
+			ensureErrorNil(tb, err)
+			return rw
+		}},
+		{"RotatingFileWriter", func(tb testing.TB, _ io.WriteCloser) io.WriteCloser {
+			w, err := NewRotatingFileWriter(filepath.Join(tb.TempDir(), "log"), 16)
+			ensureErrorNil(tb, err)
+			return w
+		}},
+		{"SamplingLineWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			lw, err := NewEveryNthSamplingLineWriter(wc, 1)
+			ensureErrorNil(tb, err)
+			return lw
+		}},
+		{"SqueezeWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			w, err := NewSqueezeWriter(wc, 1)
+			ensureErrorNil(tb, err)
+			return w
+		}},
+		{"TrimLineWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			return NewTrimLineWriter(wc)
+		}},
+		{"UTF8LineWriter", func(tb testing.TB, wc io.WriteCloser) io.WriteCloser {
+			lw, err := NewUTF8LineWriter(wc, UTF8Replace)
+			ensureErrorNil(tb, err)
+			return lw
+		}},
+	}
+
+	for _, c := range constructors {
+		t.Run(c.name, func(t *testing.T) {
+			wc := c.new(t, new(testBuffer))
+
+			ensureWrite(t, wc, "line 1\nline 2")
+			ensureErrorNil(t, wc.Close())
+
+			// Close is idempotent.
+			ensureErrorNil(t, wc.Close())
+
+			n, err := wc.Write([]byte("line 3\n"))
+			if !errors.Is(err, ErrClosed) {
+				t.Errorf("GOT: %v; WANT: %v", err, ErrClosed)
+			}
+			if got, want := n, 0; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			if rf, ok := wc.(io.ReaderFrom); ok {
+				nr, err := rf.ReadFrom(strings.NewReader("line 4\n"))
+				if !errors.Is(err, ErrClosed) {
+					t.Errorf("GOT: %v; WANT: %v", err, ErrClosed)
+				}
+				if got, want := nr, int64(0); got != want {
+					t.Errorf("GOT: %v; WANT: %v", got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("closed after write error during Close", func(t *testing.T) {
+		lw, err := NewBatchLineWriter(&errOnWrite{}, 16)
+		ensureErrorNil(t, err)
+		ensureWrite(t, lw, "line 1")
+		ensureError(t, lw.Close(), "test write error")
+		ensureErrorNil(t, lw.Close())
+		_, err = lw.Write([]byte("line 2\n"))
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("GOT: %v; WANT: %v", err, ErrClosed)
+		}
+	})
+}
+
+func TestConfigError(t *testing.T) {
+	constructors := []struct {
+		name string
+		new  func() error
+	}{
+		{"AsyncLineWriter", func() error {
+			_, err := NewAsyncLineWriter(new(testBuffer), 0, AsyncBlock)
+			return err
+		}},
+		{"BatchLineWriter", func() error {
+			_, err := NewBatchLineWriter(new(testBuffer), 0)
+			return err
+		}},
+		{"GzipMemberWriter", func() error {
+			_, err := NewGzipMemberWriter(new(testBuffer), 42)
+			return err
+		}},
+		{"LineEndingWriter", func() error {
+			_, err := NewLineEndingWriter(new(testBuffer), LineEndingConversion(42))
+			return err
+		}},
+		{"RateLimitedLineWriter", func() error {
+			_, err := NewRateLimitedLineWriter(new(testBuffer), 0, 0)
+			return err
+		}},
+		{"RetryWriter", func() error {
+			_, err := NewRetryWriter(new(testBuffer), 0, 0, 0)
+			return err
+		}},
+		{"SamplingLineWriter", func() error {
+			_, err := NewEveryNthSamplingLineWriter(new(testBuffer), 0)
+			return err
+		}},
+		{"SqueezeWriter", func() error {
+			_, err := NewSqueezeWriter(new(testBuffer), -1)
+			return err
+		}},
+		{"UTF8LineWriter", func() error {
+			_, err := NewUTF8LineWriter(new(testBuffer), UTF8Policy(42))
+			return err
+		}},
+	}
+
+	for _, c := range constructors {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.new()
+			ensureError(t, err, "cannot create "+c.name)
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("GOT: %v; WANT: %v", err, ErrInvalidConfig)
+			}
+			var ce *ConfigError
+			if !errors.As(err, &ce) {
+				t.Fatalf("GOT: %T; WANT: %T", err, ce)
+			}
+			if got, want := ce.Type, c.name; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	}
+}
+
+// invalidWriteCloser is an io.WriteCloser whose Write method returns
+// an invalid count.
+type invalidWriteCloser struct{ testBuffer }
+
+func (invalidWriteCloser) Write(p []byte) (int, error) { return -1, nil }
+
+// invalidReader is an io.Reader whose Read method returns an invalid
+// count.
+type invalidReader struct{}
+
+func (invalidReader) Read(p []byte) (int, error) { return len(p) + 1, nil }
+
+func TestWriteError(t *testing.T) {
+	errTransient := errors.New("transient")
+
+	ensureWriteError := func(t *testing.T, err error, offset, line int64) {
+		t.Helper()
+		if !errors.Is(err, errTransient) {
+			t.Errorf("GOT: %v; WANT: %v", err, errTransient)
+		}
+		var we *WriteError
+		if !errors.As(err, &we) {
+			t.Fatalf("GOT: %T; WANT: %T", err, we)
+		}
+		if got, want := we.Offset, offset; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := we.Line, line; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+
+	t.Run("BatchLineWriter", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{
+			{7, nil},
+			{3, errTransient},
+		}}
+		lw, err := NewBatchLineWriter(output, 1)
+		ensureErrorNil(t, err)
+		ensureWrite(t, lw, "line 1\n")
+
+		_, err = lw.Write([]byte("line 2\nline 3\n"))
+		ensureError(t, err, "cannot write at offset 10, line 2", "transient")
+		ensureWriteError(t, err, 10, 2)
+	})
+
+	t.Run("PerLineWriter", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{
+			{7, nil},
+			{7, nil},
+			{3, errTransient},
+		}}
+		lw := NewPerLineWriter(output)
+		ensureWrite(t, lw, "line 1\n")
+
+		_, err := lw.Write([]byte("line 2\nline 3\n"))
+		ensureWriteError(t, err, 17, 3)
+	})
+
+	t.Run("invalid write", func(t *testing.T) {
+		lw, err := NewBatchLineWriter(new(invalidWriteCloser), 1)
+		ensureErrorNil(t, err)
+		_, err = lw.Write([]byte("line 1\n"))
+		if !errors.Is(err, ErrInvalidWrite) {
+			t.Errorf("GOT: %v; WANT: %v", err, ErrInvalidWrite)
+		}
+	})
+
+	t.Run("invalid read", func(t *testing.T) {
+		lw, err := NewBatchLineWriter(new(testBuffer), 1)
+		ensureErrorNil(t, err)
+		_, err = lw.ReadFrom(invalidReader{})
+		if got, want := err, ErrInvalidRead; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
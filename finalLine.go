@@ -0,0 +1,49 @@
+package gonl
+
+// FinalLinePolicy determines what a BatchLineWriter or PerLineWriter
+// does with a final line that is not newline terminated when it is
+// closed.
+type FinalLinePolicy int
+
+const (
+	// FinalLineAsIs writes the final line without a newline.
+	FinalLineAsIs FinalLinePolicy = iota
+
+	// FinalLineAppendNewline writes the final line followed by a
+	// newline, so the output always ends with a newline, much like a
+	// LineTerminatedReader does for its input.
+	FinalLineAppendNewline
+
+	// FinalLineDiscard discards the buffered bytes of the final line.
+	FinalLineDiscard
+
+	// FinalLineError discards the buffered bytes of the final line
+	// like FinalLineDiscard, but Close also returns an
+	// *UnterminatedLineError after closing the underlying
+	// io.WriteCloser.
+	FinalLineError
+)
+
+// finalLine returns the bytes to write for tail, the bytes after the
+// final newline that remain buffered at Close, according to policy.
+// Some bytes of the final line may already have been written, without
+// a newline, by an idle flush or an overflow, which midLine reports.
+// Those bytes cannot be discarded, but a newline is still appended
+// after them, or an error returned, when policy requires it. The line
+// number of the final line is line. Unknown policies are treated as
+// FinalLineAsIs.
+func finalLine(policy FinalLinePolicy, tail []byte, midLine bool, line int64) ([]byte, error) {
+	if len(tail) == 0 && !midLine {
+		return nil, nil // output ends with a newline, or is empty
+	}
+	switch policy {
+	case FinalLineAppendNewline:
+		return append(tail, '\n'), nil
+	case FinalLineDiscard:
+		return nil, nil
+	case FinalLineError:
+		return nil, &UnterminatedLineError{Line: line, Length: len(tail)}
+	default:
+		return tail, nil
+	}
+}
@@ -0,0 +1,160 @@
+package gonl
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestFinalLinePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy FinalLinePolicy
+		input  string
+		want   string
+		err    string
+	}{
+		{"as is empty", FinalLineAsIs, "", "", ""},
+		{"as is terminated", FinalLineAsIs, "one\ntwo\n", "one\ntwo\n", ""},
+		{"as is unterminated", FinalLineAsIs, "one\ntwo", "one\ntwo", ""},
+
+		{"append newline empty", FinalLineAppendNewline, "", "", ""},
+		{"append newline terminated", FinalLineAppendNewline, "one\ntwo\n", "one\ntwo\n", ""},
+		{"append newline unterminated", FinalLineAppendNewline, "one\ntwo", "one\ntwo\n", ""},
+
+		{"discard empty", FinalLineDiscard, "", "", ""},
+		{"discard terminated", FinalLineDiscard, "one\ntwo\n", "one\ntwo\n", ""},
+		{"discard unterminated", FinalLineDiscard, "one\ntwo", "one\n", ""},
+
+		{"error empty", FinalLineError, "", "", ""},
+		{"error terminated", FinalLineError, "one\ntwo\n", "one\ntwo\n", ""},
+		{"error unterminated", FinalLineError, "one\ntwo", "one\n", "unterminated final line 2 of 3 bytes"},
+	}
+
+	writers := []struct {
+		name   string
+		create func(io.WriteCloser, FinalLinePolicy) io.WriteCloser
+	}{
+		{"BatchLineWriter", func(wc io.WriteCloser, policy FinalLinePolicy) io.WriteCloser {
+			lw, err := NewBatchLineWriter(wc, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lw.FinalLine = policy
+			return lw
+		}},
+		{"BatchLineWriter large threshold", func(wc io.WriteCloser, policy FinalLinePolicy) io.WriteCloser {
+			lw, err := NewBatchLineWriter(wc, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lw.FinalLine = policy
+			return lw
+		}},
+		{"PerLineWriter", func(wc io.WriteCloser, policy FinalLinePolicy) io.WriteCloser {
+			lw := NewPerLineWriter(wc)
+			lw.FinalLine = policy
+			return lw
+		}},
+	}
+
+	for _, w := range writers {
+		t.Run(w.name, func(t *testing.T) {
+			for _, tc := range tests {
+				t.Run(tc.name, func(t *testing.T) {
+					output := new(testBuffer)
+					lw := w.create(output, tc.policy)
+					ensureWrite(t, lw, tc.input)
+					ensureError(t, lw.Close(), tc.err)
+					if got, want := output.String(), tc.want; got != want {
+						t.Errorf("GOT: %q; WANT: %q", got, want)
+					}
+					ensureErrorNil(t, lw.Close())
+				})
+			}
+		})
+	}
+
+	t.Run("error is typed", func(t *testing.T) {
+		lw := NewPerLineWriter(new(testBuffer))
+		lw.FinalLine = FinalLineError
+		ensureWrite(t, lw, "one\ntwo\nthree")
+
+		var ue *UnterminatedLineError
+		if err := lw.Close(); !errors.As(err, &ue) {
+			t.Fatalf("GOT: %v; WANT: %T", err, ue)
+		}
+		if got, want := ue.Line, int64(3); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := ue.Length, 5; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("error closes underlying", func(t *testing.T) {
+		lw, err := NewBatchLineWriter(&errOnClose{}, 64)
+		ensureErrorNil(t, err)
+		lw.FinalLine = FinalLineError
+		ensureWrite(t, lw, "one")
+		ensureError(t, lw.Close(), "unterminated final line 1 of 3 bytes")
+		if got, want := lw.Stats().Errors, int64(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("after overflow flush", func(t *testing.T) {
+		t.Run("append newline", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewBoundedBatchLineWriter(output, 4, 4, OverflowFlush)
+			ensureErrorNil(t, err)
+			lw.FinalLine = FinalLineAppendNewline
+			ensureWrite(t, lw, "abcdefgh")
+			ensureErrorNil(t, lw.Close())
+			if got, want := output.String(), "abcdefgh\n"; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		})
+		t.Run("append newline across writes", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewBoundedBatchLineWriter(output, 4, 4, OverflowFlush)
+			ensureErrorNil(t, err)
+			lw.FinalLine = FinalLineAppendNewline
+			ensureWrite(t, lw, "abcde")
+			ensureWrite(t, lw, "fgh\n")
+			ensureWrite(t, lw, "ijkl")
+			ensureWrite(t, lw, "m")
+			ensureErrorNil(t, lw.Close())
+			if got, want := output.String(), "abcdefgh\nijklm\n"; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		})
+		t.Run("error", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewBoundedBatchLineWriter(output, 4, 4, OverflowFlush)
+			ensureErrorNil(t, err)
+			lw.FinalLine = FinalLineError
+			ensureWrite(t, lw, "abcdefgh")
+			ensureError(t, lw.Close(), "unterminated final line 1 of 4 bytes")
+			if got, want := output.String(), "abcd"; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		})
+	})
+
+	t.Run("after idle flush", func(t *testing.T) {
+		output := newSignalingWriteCloser()
+		lw := NewPerLineWriter(output)
+		lw.IdleTimeout = time.Millisecond
+		lw.FinalLine = FinalLineAppendNewline
+		ensureWrite(t, lw, "Password: ")
+		select {
+		case <-output.written:
+		case <-time.After(time.Second):
+			t.Fatal("GOT: timeout; WANT: idle flush")
+		}
+		ensureErrorNil(t, lw.Close())
+		ensureStringer(t, output, "Password: \n")
+	})
+}
@@ -0,0 +1,32 @@
+// Package gonlexpvar publishes the statistics of gonl line writers
+// through the expvar package.
+//
+// It is a separate package because importing expvar registers an HTTP
+// handler on http.DefaultServeMux, which programs that use gonl
+// without wanting their statistics published ought not pay for.
+package gonlexpvar
+
+import (
+	"expvar"
+
+	"github.com/karrick/gonl"
+)
+
+// Statser is implemented by gonl.BatchLineWriter and
+// gonl.PerLineWriter.
+type Statser interface {
+	Stats() gonl.LineWriterStats
+}
+
+// Publish publishes the statistics of s through expvar under the
+// specified name. A fresh snapshot is taken each time the variable is
+// read. Like expvar.Publish, it panics when name is already in use.
+//
+//     lw, err := gonl.NewBatchLineWriter(os.Stdout, 4096)
+//     if err != nil {
+//         return err
+//     }
+//     gonlexpvar.Publish("stdout", lw)
+func Publish(name string, s Statser) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return s.Stats() }))
+}
@@ -0,0 +1,49 @@
+package gonlexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/karrick/gonl"
+)
+
+type discardWriteCloser struct{}
+
+func (discardWriteCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (discardWriteCloser) Close() error                { return nil }
+
+// published counts the variables published by tests, so each run of a
+// test, such as with -count, publishes a unique name, because expvar
+// panics when a name is reused.
+var published int
+
+func TestPublish(t *testing.T) {
+	published++
+	name := t.Name() + "/" + strconv.Itoa(published)
+
+	lw := gonl.NewPerLineWriter(discardWriteCloser{})
+	Publish(name, lw)
+
+	if _, err := io.WriteString(lw, "line 1\nline 2\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatalf("GOT: %v; WANT: %v", v, "published variable")
+	}
+
+	var got gonl.LineWriterStats
+	if err := json.Unmarshal([]byte(v.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := got, lw.Stats(); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := got.Lines, int64(2); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
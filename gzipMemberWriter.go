@@ -0,0 +1,136 @@
+package gonl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// GzipMemberWriter is an io.WriteCloser that compresses the bytes
+// from each Write call into its own complete gzip member, and writes
+// that member to the underlying io.WriteCloser.
+//
+// It is intended to be used as the io.WriteCloser for a
+// BatchLineWriter, so each batch of lines flushed by the
+// BatchLineWriter becomes an independent gzip member. Should the
+// program crash, at most the in-flight batch is lost, and the members
+// already written may be decompressed by any gzip reader, because a
+// sequence of gzip members is itself a valid gzip stream.
+//
+// However, a member that is only partially written to the underlying
+// io.WriteCloser corrupts the stream from that point on: gzip readers
+// cannot decompress any member appended after it. Therefore, once a
+// member has been partially written, every later Write returns the
+// same error without writing anything, and the caller must start a
+// new file to continue.
+//
+//     func Example(f *os.File) error {
+//         gz, err := gonl.NewGzipMemberWriter(f, gzip.DefaultCompression)
+//         if err != nil {
+//             return err
+//         }
+//         lw, err := gonl.NewBatchLineWriter(gz, 1 << 20)
+//         if err != nil {
+//             return err
+//         }
+//         _, rerr := io.Copy(lw, os.Stdin)
+//         cerr := lw.Close() // also closes gz, which closes f
+//         if rerr == nil {
+//             return cerr
+//         }
+//         return rerr
+//     }
+type GzipMemberWriter struct {
+	// Index, when not nil, receives one line for each member written
+	// to the underlying io.WriteCloser, after the member has been
+	// written. Each line holds two decimal numbers separated by a
+	// space: the byte offset of the member in the compressed stream,
+	// and the line number, starting at 1, of the first line in the
+	// member. A reader may seek to the offset and decompress from
+	// there to find a particular line without decompressing the
+	// preceding members.
+	Index io.Writer
+
+	wc     io.WriteCloser
+	zw     *gzip.Writer
+	buf    bytes.Buffer
+	offset int64 // number of compressed bytes written
+	lines  int64 // number of newlines compressed
+	err    error // set once a member has been partially written
+}
+
+// NewGzipMemberWriter returns a new GzipMemberWriter that writes gzip
+// members compressed with the specified compression level to the
+// provided io.WriteCloser. The level may be any of the levels
+// accepted by gzip.NewWriterLevel.
+func NewGzipMemberWriter(wc io.WriteCloser, level int) (*GzipMemberWriter, error) {
+	zw, err := gzip.NewWriterLevel(nil, level)
+	if err != nil {
+		return nil, &ConfigError{Type: "GzipMemberWriter", Err: err}
+	}
+	return &GzipMemberWriter{wc: wc, zw: zw}, nil
+}
+
+// Close closes the underlying io.WriteCloser. It does not close
+// Index. Invoking Close more than once has no effect and returns nil.
+func (gw *GzipMemberWriter) Close() error {
+	if gw.wc == nil {
+		return nil // already closed
+	}
+	err := gw.wc.Close()
+	gw.wc = nil
+	return err
+}
+
+// Write compresses p into a complete gzip member, and writes that
+// member to the underlying io.WriteCloser, followed by the member's
+// entry to Index when Index is not nil. It returns len(p) when the
+// entire member was written, and 0 otherwise, because a partially
+// written member does not deliver any of p. When no bytes of the
+// member were written, Write may be retried. When some were, the
+// stream is corrupt, so this and every later Write returns the error
+// without writing anything. It returns ErrClosed after the
+// GzipMemberWriter has been closed.
+func (gw *GzipMemberWriter) Write(p []byte) (int, error) {
+	if gw.wc == nil {
+		return 0, ErrClosed
+	}
+	if gw.err != nil {
+		return 0, gw.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	gw.buf.Reset()
+	gw.zw.Reset(&gw.buf)
+	if _, err := gw.zw.Write(p); err != nil {
+		return 0, err
+	}
+	if err := gw.zw.Close(); err != nil {
+		return 0, err
+	}
+
+	nw, err := writeAll(gw.wc, gw.buf.Bytes())
+	offset := gw.offset
+	gw.offset += int64(nw)
+	if err != nil {
+		if nw > 0 {
+			gw.err = err
+		}
+		return 0, err
+	}
+
+	firstLine := gw.lines + 1
+	gw.lines += int64(bytes.Count(p, []byte{'\n'}))
+
+	if gw.Index != nil {
+		if _, err = writeAll(gw.Index, []byte(fmt.Sprintf("%d %d\n", offset, firstLine))); err != nil {
+			// The member has been written, so report p as written
+			// along with the error.
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
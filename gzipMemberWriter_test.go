@@ -0,0 +1,148 @@
+package gonl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// gunzip returns the decompressed contents of buf.
+func gunzip(tb testing.TB, buf []byte) string {
+	tb.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(buf))
+	ensureErrorNil(tb, err)
+	out, err := ioutil.ReadAll(zr)
+	ensureErrorNil(tb, err)
+	return string(out)
+}
+
+func TestGzipMemberWriter(t *testing.T) {
+	t.Run("NewGzipMemberWriter", func(t *testing.T) {
+		_, err := NewGzipMemberWriter(new(discardWriteCloser), 42)
+		ensureError(t, err, "cannot create GzipMemberWriter")
+	})
+
+	t.Run("batches", func(t *testing.T) {
+		output := new(testBuffer)
+		index := new(bytes.Buffer)
+
+		gw, err := NewGzipMemberWriter(output, gzip.BestSpeed)
+		ensureErrorNil(t, err)
+		gw.Index = index
+
+		lw, err := NewBatchLineWriter(gw, 16)
+		ensureErrorNil(t, err)
+
+		var want strings.Builder
+		for i := 1; i <= 10; i++ {
+			line := fmt.Sprintf("line %d\n", i)
+			want.WriteString(line)
+			ensureWrite(t, lw, line)
+		}
+		ensureWrite(t, lw, "final")
+		want.WriteString("final")
+		ensureErrorNil(t, lw.Close())
+
+		compressed := output.Bytes()
+		if got, want := gunzip(t, compressed), want.String(); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		entries := strings.Split(strings.TrimSuffix(index.String(), "\n"), "\n")
+		if got, want := len(entries), 4; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// Each member may be independently decompressed, starting
+		// with the line number recorded in the index.
+		for i, entry := range entries {
+			fields := strings.Fields(entry)
+			offset, err := strconv.Atoi(fields[0])
+			ensureErrorNil(t, err)
+			end := len(compressed)
+			if i+1 < len(entries) {
+				end, err = strconv.Atoi(strings.Fields(entries[i+1])[0])
+				ensureErrorNil(t, err)
+			}
+
+			member := gunzip(t, compressed[offset:end])
+			if prefix := "line " + fields[1] + "\n"; !strings.HasPrefix(member, prefix) {
+				t.Errorf("GOT: %q; WANT PREFIX: %q", member, prefix)
+			}
+		}
+
+		t.Run("truncated", func(t *testing.T) {
+			// Preceding members remain readable when final member is
+			// incomplete.
+			last := strings.Fields(entries[len(entries)-1])
+			firstLine, err := strconv.Atoi(last[1])
+			ensureErrorNil(t, err)
+			preceding := strings.Join(strings.SplitAfter(want.String(), "\n")[:firstLine-1], "")
+
+			zr, err := gzip.NewReader(bytes.NewReader(compressed[:len(compressed)-4]))
+			ensureErrorNil(t, err)
+			out, err := ioutil.ReadAll(zr)
+			ensureError(t, err, "unexpected EOF")
+			if !strings.HasPrefix(string(out), preceding) {
+				t.Errorf("GOT: %q; WANT PREFIX: %q", out, preceding)
+			}
+		})
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		output := new(testBuffer)
+		gw, err := NewGzipMemberWriter(NopCloseWriter(ShortWriter(output, 4)), gzip.BestSpeed)
+		ensureErrorNil(t, err)
+
+		n, err := gw.Write([]byte("line 1\n"))
+		ensureError(t, err, "short write")
+		if got, want := n, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("retry after nothing written", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{{0, io.ErrShortWrite}}}
+		gw, err := NewGzipMemberWriter(output, gzip.BestSpeed)
+		ensureErrorNil(t, err)
+
+		_, err = gw.Write([]byte("line 1\n"))
+		ensureError(t, err, "short write")
+		ensureWrite(t, gw, "line 1\n")
+		ensureErrorNil(t, gw.Close())
+
+		if got, want := gunzip(t, output.Bytes()), "line 1\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("refuses writes after partial member", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{{1 << 20, nil}, {4, io.ErrShortWrite}}}
+		index := new(testBuffer)
+		gw, err := NewGzipMemberWriter(output, gzip.BestSpeed)
+		ensureErrorNil(t, err)
+		gw.Index = index
+
+		ensureWrite(t, gw, "line 1\n")
+		size := len(output.Bytes())
+
+		_, err = gw.Write([]byte("line 2\n"))
+		ensureError(t, err, "short write")
+
+		n, err := gw.Write([]byte("line 2\n"))
+		ensureError(t, err, "short write")
+		if got, want := n, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := len(output.Bytes()), size+4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStringer(t, index, "0 1\n")
+		ensureErrorNil(t, gw.Close())
+	})
+}
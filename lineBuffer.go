@@ -0,0 +1,82 @@
+package gonl
+
+import "bytes"
+
+// lineBuffer accumulates bytes from successive writes and hands each
+// completed newline terminated line to a callback. It is used by the
+// line writers that need to inspect or transform entire lines before
+// sending them to their underlying io.WriteCloser.
+type lineBuffer struct {
+	buf []byte
+	off int // read at buf[off:]; write at buf[:len(buf)]
+}
+
+// buffered returns the bytes of the partial line that have not yet
+// been given to a callback.
+func (lb *lineBuffer) buffered() []byte { return lb.buf[lb.off:] }
+
+// reset discards all buffered bytes, but keeps the allocated backing
+// array.
+func (lb *lineBuffer) reset() {
+	lb.buf = lb.buf[:0]
+	lb.off = 0
+}
+
+// write appends p to the buffer, then invokes emit once for each
+// completed line, including its newline. Bytes after the final
+// newline remain buffered until a later write completes the line, or
+// until flush is invoked.
+//
+// When emit returns an error, write returns that error along with the
+// number of bytes from p that belonged to lines which were
+// successfully emitted. Bytes from p following that count are removed
+// from the buffer, so the caller may retry them, while bytes buffered
+// before write was invoked are retained.
+func (lb *lineBuffer) write(p []byte, emit func([]byte) error) (int, error) {
+	if lb.off > 0 {
+		// Slide the partial line to the front of the buffer to reduce
+		// the likelihood of unnecessary allocation.
+		lb.buf = lb.buf[:copy(lb.buf, lb.buf[lb.off:])]
+		lb.off = 0
+	}
+	leno := len(lb.buf)
+	lb.buf = append(lb.buf, p...)
+
+	// Bytes buffered before this write do not have a newline, so
+	// start searching with the new bytes.
+	search := leno
+
+	for {
+		index := bytes.IndexByte(lb.buf[search:], '\n')
+		if index == -1 {
+			return len(p), nil
+		}
+		index += search + 1 // extra byte to include newline
+
+		if err := emit(lb.buf[lb.off:index]); err != nil {
+			if lb.off == 0 {
+				// Nothing emitted, so keep only the bytes this had
+				// before being invoked.
+				lb.buf = lb.buf[:leno]
+				return 0, err
+			}
+			// The line that failed came entirely from p.
+			lb.buf = lb.buf[:lb.off]
+			return lb.off - leno, err
+		}
+		lb.off = index
+		search = index
+	}
+}
+
+// flush invokes emit with any buffered bytes not terminated by a
+// newline. The buffer is reset regardless of whether emit returns an
+// error.
+func (lb *lineBuffer) flush(emit func([]byte) error) error {
+	if len(lb.buf)-lb.off == 0 {
+		return nil
+	}
+	err := emit(lb.buf[lb.off:])
+	lb.reset()
+	return err
+}
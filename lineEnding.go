@@ -0,0 +1,222 @@
+package gonl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// LineEndingConversion specifies how a LineEndingReader or
+// LineEndingWriter converts line endings.
+type LineEndingConversion int
+
+const (
+	// CRLFToLF converts each CRLF sequence to LF. A CR that is not
+	// followed by LF is not changed.
+	CRLFToLF LineEndingConversion = iota
+
+	// LFToCRLF converts each LF that is not preceded by CR to a CRLF
+	// sequence. Existing CRLF sequences are not changed.
+	LFToCRLF
+
+	// CRToLF converts each CR that is not followed by LF to LF. CRLF
+	// sequences are not changed.
+	CRToLF
+)
+
+// lineEndingConverter converts line endings of a stream provided in
+// chunks, remembering a CR at the end of one chunk, so it may be
+// converted once the first byte of the next chunk is known.
+type lineEndingConverter struct {
+	conversion LineEndingConversion
+	pendingCR  bool // CR held until the following byte is known
+	prevCR     bool // previous byte was CR, for LFToCRLF
+}
+
+func newLineEndingConverter(typ string, conversion LineEndingConversion) (lineEndingConverter, error) {
+	switch conversion {
+	case CRLFToLF, LFToCRLF, CRToLF:
+		return lineEndingConverter{conversion: conversion}, nil
+	default:
+		return lineEndingConverter{}, &ConfigError{Type: typ, Reason: fmt.Sprintf("with unknown conversion: %d", conversion)}
+	}
+}
+
+// appendByte appends the conversion of b to dst.
+func (c *lineEndingConverter) appendByte(dst []byte, b byte) []byte {
+	if c.pendingCR {
+		c.pendingCR = false
+		if b == '\n' {
+			if c.conversion == CRToLF {
+				dst = append(dst, '\r')
+			}
+			return append(dst, '\n')
+		}
+		// Lone CR
+		if c.conversion == CRToLF {
+			dst = append(dst, '\n')
+		} else {
+			dst = append(dst, '\r')
+		}
+	}
+
+	switch b {
+	case '\r':
+		if c.conversion != LFToCRLF {
+			c.pendingCR = true
+			return dst
+		}
+		c.prevCR = true
+		return append(dst, '\r')
+	case '\n':
+		if c.conversion == LFToCRLF && !c.prevCR {
+			dst = append(dst, '\r')
+		}
+	}
+	c.prevCR = false
+	return append(dst, b)
+}
+
+// convert appends the conversion of src to dst.
+func (c *lineEndingConverter) convert(dst, src []byte) []byte {
+	for len(src) > 0 {
+		if !c.pendingCR && !c.prevCR {
+			i := bytes.IndexAny(src, "\r\n")
+			if i == -1 {
+				return append(dst, src...)
+			}
+			dst = append(dst, src[:i]...)
+			src = src[i:]
+		}
+		dst = c.appendByte(dst, src[0])
+		src = src[1:]
+	}
+	return dst
+}
+
+// flush appends the conversion of a CR held at the end of the stream
+// to dst.
+func (c *lineEndingConverter) flush(dst []byte) []byte {
+	if !c.pendingCR {
+		return dst
+	}
+	c.pendingCR = false
+	if c.conversion == CRToLF {
+		return append(dst, '\n')
+	}
+	return append(dst, '\r')
+}
+
+// LineEndingReader is an io.Reader that converts the line endings of
+// the bytes read from the source io.Reader, including a CR at the end
+// of one Read whose LF is returned by the next.
+//
+// To also ensure the converted stream ends with a line terminator,
+// convert the output of a LineTerminatedReader, so its final newline
+// is converted along with the others.
+//
+//     r, err := gonl.NewLineEndingReader(&gonl.LineTerminatedReader{R: os.Stdin}, gonl.LFToCRLF)
+//     if err != nil {
+//         return err
+//     }
+//     _, err = io.Copy(os.Stdout, r)
+type LineEndingReader struct {
+	tr   transformReader
+	conv lineEndingConverter
+}
+
+// NewLineEndingReader returns a new LineEndingReader that reads from
+// r, converting line endings as specified by conversion.
+func NewLineEndingReader(r io.Reader, conversion LineEndingConversion) (*LineEndingReader, error) {
+	conv, err := newLineEndingConverter("LineEndingReader", conversion)
+	if err != nil {
+		return nil, err
+	}
+	lr := &LineEndingReader{conv: conv}
+	lr.tr = transformReader{r: r, transform: lr.conv.convert, flush: lr.conv.flush}
+	return lr, nil
+}
+
+// Read reads up to len(p) converted bytes into p. It returns the
+// number of bytes read (0 <= n <= len(p)) and any error encountered.
+// The error from the source io.Reader is returned after all bytes
+// converted prior to it have been returned.
+func (r *LineEndingReader) Read(p []byte) (int, error) { return r.tr.Read(p) }
+
+// LineEndingWriter is an io.WriteCloser that converts the line endings
+// of the bytes written to it, including a CR at the end of one Write
+// whose LF is provided by the next, then writes the converted bytes to
+// the underlying io.WriteCloser.
+//
+// It is important for caller to Close the LineEndingWriter to write a
+// CR at the end of the final Write.
+type LineEndingWriter struct {
+	wc   io.WriteCloser
+	conv lineEndingConverter
+	out  []byte
+}
+
+// NewLineEndingWriter returns a new LineEndingWriter that writes to
+// wc, converting line endings as specified by conversion.
+func NewLineEndingWriter(wc io.WriteCloser, conversion LineEndingConversion) (*LineEndingWriter, error) {
+	conv, err := newLineEndingConverter("LineEndingWriter", conversion)
+	if err != nil {
+		return nil, err
+	}
+	return &LineEndingWriter{wc: wc, conv: conv}, nil
+}
+
+// Close writes the conversion of a CR at the end of the final Write,
+// then closes the underlying io.WriteCloser. Invoking Close more than
+// once has no effect and returns nil.
+func (w *LineEndingWriter) Close() error {
+	if w.wc == nil {
+		return nil // already closed
+	}
+	var err error
+	if out := w.conv.flush(w.out[:0]); len(out) > 0 {
+		_, err = writeAll(w.wc, out)
+	}
+	if cerr := w.wc.Close(); err == nil {
+		err = cerr
+	}
+	w.wc = nil
+	return err
+}
+
+// Write converts the line endings of p, and writes the converted
+// bytes to the underlying io.WriteCloser. When that fails, it returns
+// the number of bytes from p whose conversion was entirely written,
+// along with the error. It returns ErrClosed after the
+// LineEndingWriter has been closed.
+func (w *LineEndingWriter) Write(p []byte) (int, error) {
+	if w.wc == nil {
+		return 0, ErrClosed
+	}
+
+	saved := w.conv
+	w.out = w.conv.convert(w.out[:0], p)
+	nw, err := writeAll(w.wc, w.out)
+	if err == nil {
+		return len(p), nil
+	}
+
+	// Find how many bytes of p were converted to the nw bytes
+	// written, and restore the converter to its state after them.
+	var tmp [4]byte
+	var total, delivered int
+	sim, state := saved, saved
+	for i, b := range p {
+		k := len(sim.appendByte(tmp[:0], b))
+		if total+k > nw {
+			break
+		}
+		total += k
+		if !sim.pendingCR {
+			delivered = i + 1
+			state = sim
+		}
+	}
+	w.conv = state
+	return delivered, err
+}
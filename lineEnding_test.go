@@ -0,0 +1,110 @@
+package gonl
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLineEnding(t *testing.T) {
+	cases := []struct {
+		name       string
+		conversion LineEndingConversion
+		input      string
+		want       string
+	}{
+		{"CRLFToLF", CRLFToLF, "a\r\nb\rc\n\r\r\nd\r", "a\nb\rc\n\r\nd\r"},
+		{"LFToCRLF", LFToCRLF, "a\r\nb\rc\n\n\r", "a\r\nb\rc\r\n\r\n\r"},
+		{"CRToLF", CRToLF, "a\r\nb\rc\n\r\r\nd\r", "a\r\nb\nc\n\n\r\nd\n"},
+	}
+
+	t.Run("LineEndingReader", func(t *testing.T) {
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				r, err := NewLineEndingReader(strings.NewReader(c.input), c.conversion)
+				ensureErrorNil(t, err)
+				buf, err := ioutil.ReadAll(r)
+				ensureErrorNil(t, err)
+				if got, want := string(buf), c.want; got != want {
+					t.Errorf("GOT: %q; WANT: %q", got, want)
+				}
+			})
+			t.Run(c.name+" one byte reads", func(t *testing.T) {
+				r, err := NewLineEndingReader(iotest.OneByteReader(strings.NewReader(c.input)), c.conversion)
+				ensureErrorNil(t, err)
+				buf, err := ioutil.ReadAll(iotest.OneByteReader(r))
+				ensureErrorNil(t, err)
+				if got, want := string(buf), c.want; got != want {
+					t.Errorf("GOT: %q; WANT: %q", got, want)
+				}
+			})
+		}
+	})
+
+	t.Run("LineEndingWriter", func(t *testing.T) {
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				output := new(testBuffer)
+				w, err := NewLineEndingWriter(output, c.conversion)
+				ensureErrorNil(t, err)
+				ensureWrite(t, w, c.input)
+				ensureErrorNil(t, w.Close())
+				ensureStringer(t, output, c.want)
+			})
+			t.Run(c.name+" one byte writes", func(t *testing.T) {
+				output := new(testBuffer)
+				w, err := NewLineEndingWriter(output, c.conversion)
+				ensureErrorNil(t, err)
+				for i := 0; i < len(c.input); i++ {
+					ensureWrite(t, w, c.input[i:i+1])
+				}
+				ensureErrorNil(t, w.Close())
+				ensureStringer(t, output, c.want)
+			})
+		}
+	})
+
+	t.Run("unknown conversion", func(t *testing.T) {
+		_, err := NewLineEndingReader(strings.NewReader(""), LineEndingConversion(42))
+		ensureError(t, err, "cannot create LineEndingReader with unknown conversion")
+		_, err = NewLineEndingWriter(new(testBuffer), LineEndingConversion(42))
+		ensureError(t, err, "cannot create LineEndingWriter with unknown conversion")
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{{3, io.ErrShortWrite}}}
+		w, err := NewLineEndingWriter(output, LFToCRLF)
+		ensureErrorNil(t, err)
+
+		// "a\r\nb\r\n" is converted, but only "a\r\n" written.
+		n, err := w.Write([]byte("a\nb\n"))
+		ensureError(t, err, io.ErrShortWrite.Error())
+		if got, want := n, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureWrite(t, w, "b\n")
+		ensureErrorNil(t, w.Close())
+		ensureStringer(t, output, "a\r\nb\r\n")
+	})
+
+	t.Run("LineTerminatedReader", func(t *testing.T) {
+		for _, c := range []struct {
+			input string
+			want  string
+		}{
+			{"a\nb", "a\r\nb\r\n"},
+			{"a\nb\r", "a\r\nb\r\n"},
+			{"a\nb\n", "a\r\nb\r\n"},
+		} {
+			r, err := NewLineEndingReader(&LineTerminatedReader{R: strings.NewReader(c.input)}, LFToCRLF)
+			ensureErrorNil(t, err)
+			buf, err := ioutil.ReadAll(r)
+			ensureErrorNil(t, err)
+			if got, want := string(buf), c.want; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		}
+	})
+}
@@ -59,3 +59,88 @@ func (r *LineTerminatedReader) Read(p []byte) (int, error) {
 	r.savedErr = err
 	return n, nil
 }
+
+// WriteTo writes the bytes read from the source io.Reader to w until
+// io.EOF or error, followed by a newline when the final byte was not
+// a newline. It returns the number of bytes written, including the
+// newline, and any error except io.EOF encountered. Like Read, when
+// the source io.Reader returns an error that wraps io.EOF, the newline
+// is written, and that error is returned.
+//
+// When the source io.Reader implements io.WriterTo, this delegates to
+// its WriteTo method. Otherwise, when w implements io.ReaderFrom, such
+// as BatchLineWriter, this invokes its ReadFrom method, so bytes are
+// read directly into its buffer rather than through an intermediate
+// one.
+//
+// This method is provided to satisfy the io.WriterTo interface, which
+// the io.Copy function uses if available.
+func (r *LineTerminatedReader) WriteTo(w io.Writer) (int64, error) {
+	if r.savedErr != nil {
+		// A previous Read received EOF from the underlying io.Reader,
+		// but had no room to return the newline.
+		err := r.savedErr
+		r.savedErr = nil
+		nw, werr := w.Write([]byte{'\n'})
+		if werr != nil {
+			return int64(nw), werr
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		return int64(nw), err
+	}
+
+	var n int64
+	var err error
+
+	if wt, ok := r.R.(io.WriterTo); ok {
+		n, err = wt.WriteTo(&finalByteWriter{w: w, r: r})
+	} else {
+		// io.Copy invokes w.ReadFrom when w is an io.ReaderFrom.
+		n, err = io.Copy(w, &finalByteReader{r: r})
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, err
+	}
+	// POST: Reached EOF, either reported as a nil error, or wrapped.
+
+	if !r.wasFinalByteNewline {
+		nw, werr := w.Write([]byte{'\n'})
+		n += int64(nw)
+		if werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// finalByteReader reads from the source io.Reader of a
+// LineTerminatedReader, recording whether the final byte read was a
+// newline.
+type finalByteReader struct {
+	r *LineTerminatedReader
+}
+
+func (fr *finalByteReader) Read(p []byte) (int, error) {
+	n, err := fr.r.R.Read(p)
+	if n > 0 && n <= len(p) {
+		fr.r.wasFinalByteNewline = p[n-1] == '\n'
+	}
+	return n, err
+}
+
+// finalByteWriter writes to w, recording in the LineTerminatedReader
+// whether the final byte written was a newline.
+type finalByteWriter struct {
+	w io.Writer
+	r *LineTerminatedReader
+}
+
+func (fw *finalByteWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if n > 0 && n <= len(p) {
+		fw.r.wasFinalByteNewline = p[n-1] == '\n'
+	}
+	return n, err
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 // newTestReader returns a LineTerminatedReader that reads from a
@@ -255,3 +256,100 @@ func TestLineTerminatedReader(t *testing.T) {
 		})
 	})
 }
+
+func TestLineTerminatedReaderWriteTo(t *testing.T) {
+	inputs := []string{"", "\n", "one", "one\n", "one\ntwo", "one\ntwo\n\n"}
+
+	sources := []struct {
+		name   string
+		create func(string) io.Reader
+	}{
+		{"io.WriterTo", func(s string) io.Reader { return strings.NewReader(s) }},
+		{"io.Reader", func(s string) io.Reader { return iotest.OneByteReader(strings.NewReader(s)) }},
+	}
+
+	for _, source := range sources {
+		t.Run(source.name, func(t *testing.T) {
+			for _, input := range inputs {
+				want, err := ioutil.ReadAll(&LineTerminatedReader{R: source.create(input)})
+				ensureErrorNil(t, err)
+
+				t.Run(fmt.Sprintf("%q", input), func(t *testing.T) {
+					output := new(testBuffer)
+					n, err := io.Copy(output, &LineTerminatedReader{R: source.create(input)})
+					ensureErrorNil(t, err)
+					if got, want := n, int64(len(want)); got != want {
+						t.Errorf("GOT: %v; WANT: %v", got, want)
+					}
+					ensureStringer(t, output, string(want))
+				})
+
+				t.Run(fmt.Sprintf("%q to BatchLineWriter", input), func(t *testing.T) {
+					output := new(testBuffer)
+					lw, err := NewBatchLineWriter(output, 64)
+					ensureErrorNil(t, err)
+					_, err = (&LineTerminatedReader{R: source.create(input)}).WriteTo(lw)
+					ensureErrorNil(t, err)
+					ensureErrorNil(t, lw.Close())
+					ensureStringer(t, output, string(want))
+				})
+			}
+		})
+	}
+
+	t.Run("read error", func(t *testing.T) {
+		output := new(testBuffer)
+		r := newTestReader([]tuple{
+			tuple{"one", errors.New("test read error")},
+		})
+		n, err := r.WriteTo(output)
+		ensureError(t, err, "test read error")
+		if got, want := n, int64(3); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStringer(t, output, "one")
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		r := &LineTerminatedReader{R: strings.NewReader("one")}
+		n, err := r.WriteTo(&errOnWrite{})
+		ensureError(t, err, "test write error")
+		if got, want := n, int64(0); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("wrapped io.EOF errors", func(t *testing.T) {
+		output := new(testBuffer)
+		wrappedErr := &ErrIO{Err: io.EOF}
+		r := newTestReader([]tuple{
+			tuple{"12345", wrappedErr},
+		})
+		n, err := r.WriteTo(output)
+		if err != wrappedErr {
+			t.Errorf("GOT: %T(%v); WANT: %T(%v)", err, err, wrappedErr, wrappedErr)
+		}
+		if got, want := n, int64(6); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStringer(t, output, "12345\n")
+	})
+
+	t.Run("after final read has no room", func(t *testing.T) {
+		r := newTestReader([]tuple{
+			tuple{"12345", io.EOF},
+		})
+		buf := make([]byte, 5)
+		n, err := r.Read(buf)
+		ensureErrorNil(t, err)
+		ensureBufferLimit(t, buf, n, "12345")
+
+		output := new(testBuffer)
+		nw, err := r.WriteTo(output)
+		ensureErrorNil(t, err)
+		if got, want := nw, int64(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStringer(t, output, "\n")
+	})
+}
@@ -2,6 +2,7 @@ package gonl
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 )
@@ -11,12 +12,22 @@ import (
 // lines read. It will return the same number regardless of whether
 // the final Read terminated in a newline character or not.
 func NewlineCounter(r io.Reader) (int, error) {
+	return NewlineCounterContext(context.Background(), r)
+}
+
+// NewlineCounterContext is like NewlineCounter, but checks whether ctx
+// is done before each read from r. When ctx is done, it returns the
+// number of lines counted so far, along with ctx.Err().
+func NewlineCounterContext(ctx context.Context, r io.Reader) (int, error) {
 	buf := make([]byte, 4096)
 	var err error
 	var newlines, total, n int
 	var isNotFinalNewline bool
 
 	for {
+		if err = ctx.Err(); err != nil {
+			break // do not try to read more if done
+		}
 		n, err = r.Read(buf)
 		if n > 0 {
 			total += n
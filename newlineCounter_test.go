@@ -1,6 +1,7 @@
 package gonl
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -86,4 +87,34 @@ func TestNewlineCounter(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("context", func(t *testing.T) {
+		t.Run("not canceled", func(t *testing.T) {
+			c, err := NewlineCounterContext(context.Background(), strings.NewReader("one\ntwo\nthree"))
+			ensureError(t, err)
+			if got, want := c, 3; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+		t.Run("canceled between reads", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			r := &cancelingReader{
+				Reader: &testReader{tuples: []tuple{
+					tuple{"one\ntwo\n", nil},
+				}},
+				cancel: cancel,
+				reads:  1,
+			}
+
+			c, err := NewlineCounterContext(ctx, r)
+			if got, want := err, context.Canceled; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := c, 2; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
 }
@@ -0,0 +1,197 @@
+package gonl
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// NumberFormat determines how a NumberLineWriter formats line numbers
+// narrower than its Width.
+type NumberFormat int
+
+const (
+	// NumberRight right justifies line numbers, padding them with
+	// leading spaces, like `cat -n` and `nl -n rn`.
+	NumberRight NumberFormat = iota
+
+	// NumberLeft left justifies line numbers, padding them with
+	// trailing spaces, like `nl -n ln`.
+	NumberLeft
+
+	// NumberRightZero right justifies line numbers, padding them with
+	// leading zeros, like `nl -n rz`.
+	NumberRightZero
+)
+
+// NumberLineWriter is an io.WriteCloser that prefixes each line written
+// to it with its line number and a separator, then writes it to the
+// underlying io.WriteCloser, like `cat -n` and `nl`.
+//
+// Because each line is numbered once it is completed, line numbers do
+// not depend on how the bytes are split across Write calls. A final
+// line that is not newline terminated is numbered when the
+// NumberLineWriter is closed.
+//
+//     func Example() error {
+//         lw := gonl.NewNumberLineWriter(os.Stdout)
+//         lw.NonEmptyOnly = true // like cat -b
+//
+//         _, rerr := io.Copy(lw, os.Stdin)
+//
+//         cerr := lw.Close()
+//         if rerr == nil {
+//             return cerr
+//         }
+//         return rerr
+//     }
+//
+// It is important for caller to Close the NumberLineWriter to number
+// and write the final line when it is not terminated with a newline.
+type NumberLineWriter struct {
+	// Format determines how line numbers narrower than Width are
+	// padded. The default is NumberRight.
+	Format NumberFormat
+
+	// Width is the minimum number of bytes used for each line number.
+	// Line numbers with more digits are not truncated. The default is
+	// 6.
+	Width int
+
+	// Separator is written between each line number and its line. The
+	// default is a tab.
+	Separator string
+
+	// Start is the number of the first line, and of the line following
+	// each ResetLine. The default is 1.
+	Start int64
+
+	// NonEmptyOnly, when true, causes lines that have no bytes other
+	// than their LF or CRLF line ending to be written without a line
+	// number, and without consuming one, like `cat -b`.
+	NonEmptyOnly bool
+
+	// ResetLine, when not empty, causes each line that is equal to it,
+	// once its LF or CRLF line ending is removed, to be written without
+	// a line number, and the following line to be numbered Start.
+	ResetLine string
+
+	lb      lineBuffer
+	wc      io.WriteCloser
+	next    int64  // number of the next numbered line
+	started bool   // false until next is initialized from Start
+	out     []byte // numbered bytes not yet written
+}
+
+// NewNumberLineWriter returns a new NumberLineWriter that writes
+// numbered lines to the provided io.WriteCloser, formatted like
+// `cat -n`: right justified to a width of 6, followed by a tab,
+// beginning with 1. The exported fields may be changed before the
+// first Write.
+func NewNumberLineWriter(wc io.WriteCloser) *NumberLineWriter {
+	return &NumberLineWriter{
+		wc:        wc,
+		Width:     6,
+		Separator: "\t",
+		Start:     1,
+	}
+}
+
+// Close numbers and writes a final line that is not terminated with a
+// newline, along with any bytes that remain buffered because of a
+// previous write error, then closes the underlying io.WriteCloser.
+// Invoking Close more than once has no effect and returns nil.
+func (lw *NumberLineWriter) Close() error {
+	if lw.wc == nil {
+		return nil // already closed
+	}
+	_ = lw.lb.flush(lw.number)
+	var err error
+	if len(lw.out) > 0 {
+		_, err = writeAll(lw.wc, lw.out)
+	}
+	if cerr := lw.wc.Close(); err == nil {
+		err = cerr
+	}
+	lw.wc = nil
+	lw.out = nil
+	return err
+}
+
+// number appends line to lw.out, prefixed with its line number when
+// it is numbered. It never returns an error, so lineBuffer emits each
+// completed line.
+func (lw *NumberLineWriter) number(line []byte) error {
+	if !lw.started {
+		lw.next = lw.Start
+		lw.started = true
+	}
+
+	content := line
+	if l := len(content); l > 0 && content[l-1] == '\n' {
+		content = content[:l-1]
+		if l := len(content); l > 0 && content[l-1] == '\r' {
+			content = content[:l-1]
+		}
+	}
+
+	switch {
+	case lw.ResetLine != "" && string(content) == lw.ResetLine:
+		lw.next = lw.Start
+	case lw.NonEmptyOnly && len(content) == 0:
+	default:
+		lw.out = lw.appendNumber(lw.out, lw.next)
+		lw.out = append(lw.out, lw.Separator...)
+		lw.next++
+	}
+
+	lw.out = append(lw.out, line...)
+	return nil
+}
+
+// appendNumber appends n to dst, padded to Width according to Format.
+func (lw *NumberLineWriter) appendNumber(dst []byte, n int64) []byte {
+	var scratch [20]byte // enough for any int64
+	digits := strconv.AppendInt(scratch[:0], n, 10)
+	pad := lw.Width - len(digits)
+	if pad < 0 {
+		pad = 0
+	}
+
+	switch lw.Format {
+	case NumberLeft:
+		dst = append(dst, digits...)
+		return append(dst, bytes.Repeat([]byte{' '}, pad)...)
+	case NumberRightZero:
+		if digits[0] == '-' {
+			dst = append(dst, '-')
+			digits = digits[1:]
+		}
+		dst = append(dst, bytes.Repeat([]byte{'0'}, pad)...)
+		return append(dst, digits...)
+	default:
+		dst = append(dst, bytes.Repeat([]byte{' '}, pad)...)
+		return append(dst, digits...)
+	}
+}
+
+// Write buffers p, and writes each completed line to the underlying
+// io.WriteCloser, prefixed with its line number. Because the bytes
+// written no longer correspond to the bytes of p, when writing fails,
+// it returns len(p) along with the error, and the bytes not written
+// remain buffered, to be written by the next Write or by Close. It
+// returns ErrClosed after the NumberLineWriter has been closed.
+func (lw *NumberLineWriter) Write(p []byte) (int, error) {
+	if lw.wc == nil {
+		return 0, ErrClosed
+	}
+
+	_, _ = lw.lb.write(p, lw.number)
+
+	if len(lw.out) == 0 {
+		return len(p), nil
+	}
+	nw, err := writeAll(lw.wc, lw.out)
+	lw.out = lw.out[:copy(lw.out, lw.out[nw:])]
+	return len(p), err
+}
@@ -0,0 +1,111 @@
+package gonl
+
+import (
+	"io"
+	"testing"
+)
+
+func TestNumberLineWriter(t *testing.T) {
+	cases := []struct {
+		name   string
+		config func(*NumberLineWriter)
+		input  string
+		want   string
+	}{
+		{"empty", nil, "", ""},
+		{"defaults", nil, "one\n\ntwo\n", "     1\tone\n     2\t\n     3\ttwo\n"},
+		{"unterminated", nil, "one\ntwo", "     1\tone\n     2\ttwo"},
+		{"wide number", func(lw *NumberLineWriter) {
+			lw.Width = 2
+			lw.Start = 99
+		}, "a\nb\n", "99\ta\n100\tb\n"},
+		{"left", func(lw *NumberLineWriter) {
+			lw.Format = NumberLeft
+			lw.Width = 3
+			lw.Separator = "| "
+		}, "a\nb\n", "1  | a\n2  | b\n"},
+		{"right zero", func(lw *NumberLineWriter) {
+			lw.Format = NumberRightZero
+			lw.Width = 3
+			lw.Separator = " "
+		}, "a\nb\n", "001 a\n002 b\n"},
+		{"right zero negative", func(lw *NumberLineWriter) {
+			lw.Format = NumberRightZero
+			lw.Width = 3
+			lw.Separator = " "
+			lw.Start = -1
+		}, "a\nb\nc\n", "-01 a\n000 b\n001 c\n"},
+		{"zero width", func(lw *NumberLineWriter) {
+			lw.Width = 0
+			lw.Separator = ": "
+		}, "a\n", "1: a\n"},
+		{"non-empty only", func(lw *NumberLineWriter) {
+			lw.NonEmptyOnly = true
+		}, "one\n\r\n\ntwo\n", "     1\tone\n\r\n\n     2\ttwo\n"},
+		{"reset line", func(lw *NumberLineWriter) {
+			lw.ResetLine = "---"
+			lw.Width = 1
+		}, "a\nb\n---\r\nc\n---\n---\nd", "1\ta\n2\tb\n---\r\n1\tc\n---\n---\n1\td"},
+		{"reset line with start", func(lw *NumberLineWriter) {
+			lw.ResetLine = "---"
+			lw.Start = 10
+			lw.Width = 1
+		}, "a\n---\nb\n", "10\ta\n---\n10\tb\n"},
+		{"reset line unterminated", func(lw *NumberLineWriter) {
+			lw.ResetLine = "---"
+			lw.Width = 1
+		}, "a\n---", "1\ta\n---"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewNumberLineWriter(output)
+			if c.config != nil {
+				c.config(lw)
+			}
+			ensureWrite(t, lw, c.input)
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, c.want)
+		})
+		t.Run(c.name+" one byte writes", func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewNumberLineWriter(output)
+			if c.config != nil {
+				c.config(lw)
+			}
+			for i := 0; i < len(c.input); i++ {
+				ensureWrite(t, lw, c.input[i:i+1])
+			}
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, c.want)
+		})
+	}
+
+	t.Run("writes completed lines", func(t *testing.T) {
+		output := new(testBuffer)
+		lw := NewNumberLineWriter(output)
+		lw.Width = 1
+
+		ensureWrite(t, lw, "one\ntw")
+		ensureStringer(t, output, "1\tone\n")
+		ensureWrite(t, lw, "o\n")
+		ensureStringer(t, output, "1\tone\n2\ttwo\n")
+		ensureErrorNil(t, lw.Close())
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{{3, io.ErrShortWrite}}}
+		lw := NewNumberLineWriter(output)
+		lw.Width = 1
+
+		n, err := lw.Write([]byte("one\n"))
+		ensureError(t, err, io.ErrShortWrite.Error())
+		if got, want := n, 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureWrite(t, lw, "two\n")
+		ensureErrorNil(t, lw.Close())
+		ensureStringer(t, output, "1\tone\n2\ttwo\n")
+	})
+}
@@ -1,5 +1,7 @@
 package gonl
 
+import "io"
+
 // OneNewline returns a string with exactly one terminating newline
 // character. More simple than strings.TrimRight. When input string
 // ends with multiple OneNewline characters, it will strip off all but
@@ -31,3 +33,60 @@ func OneNewline(s string) string {
 	// return the first one.
 	return s[:1]
 }
+
+// OneNewlineReader is an io.Reader that provides the semantics of
+// OneNewline for a stream: the bytes read from it end with exactly one
+// newline. Redundant newlines at the end of the source io.Reader are
+// removed, a newline is appended when the source does not end with
+// one, and an empty source produces a single newline. Newlines before
+// the final non-newline byte are not changed.
+//
+// Only a count of the run of newlines at the end of each read is held,
+// until it is known whether more bytes follow, so the input is never
+// buffered in its entirety.
+type OneNewlineReader struct {
+	tr   transformReader
+	held int // newlines not yet returned
+}
+
+// NewOneNewlineReader returns a new OneNewlineReader that reads from r.
+func NewOneNewlineReader(r io.Reader) *OneNewlineReader {
+	onr := new(OneNewlineReader)
+	onr.tr = transformReader{r: r, transform: onr.transform, flush: onr.flush}
+	return onr
+}
+
+// Read reads up to len(p) bytes into p. It returns the number of bytes
+// read (0 <= n <= len(p)) and any error encountered. Like
+// LineTerminatedReader, only io.EOF, or an error that wraps it, ends
+// the stream, and is returned after all bytes read prior to it, and
+// the final newline, have been returned. Any other error is returned
+// without the held newlines or the final newline, which remain held,
+// so a later Read may continue reading from the source io.Reader.
+func (r *OneNewlineReader) Read(p []byte) (int, error) { return r.tr.Read(p) }
+
+// transform appends src to dst, except for its trailing run of
+// newlines, which is held.
+func (r *OneNewlineReader) transform(dst, src []byte) []byte {
+	i := len(src) - 1
+	for i >= 0 && src[i] == '\n' {
+		i--
+	}
+	if i == -1 {
+		r.held += len(src)
+		return dst
+	}
+	for ; r.held > 0; r.held-- {
+		dst = append(dst, '\n')
+	}
+	dst = append(dst, src[:i+1]...)
+	r.held = len(src) - i - 1
+	return dst
+}
+
+// flush appends the single newline that ends the stream to dst. It is
+// only invoked at EOF.
+func (r *OneNewlineReader) flush(dst []byte) []byte {
+	r.held = 0
+	return append(dst, '\n')
+}
@@ -1,8 +1,13 @@
 package gonl
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func ExampleOneNewline() {
@@ -55,3 +60,78 @@ func TestOneNewline(t *testing.T) {
 		}
 	})
 }
+
+func TestOneNewlineReader(t *testing.T) {
+	inputs := []string{
+		"",
+		"a",
+		"\n",
+		"\n\n\n",
+		"abc\n",
+		"abc\n\n\n",
+		"\n\nabc",
+		"abc\n\ndef\n\n",
+		"héllo wörld\n\n",
+		"a\r\n\r\n",
+	}
+
+	for _, input := range inputs {
+		want := OneNewline(input)
+
+		t.Run(fmt.Sprintf("%q", input), func(t *testing.T) {
+			buf, err := ioutil.ReadAll(NewOneNewlineReader(strings.NewReader(input)))
+			ensureErrorNil(t, err)
+			if got := string(buf); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		})
+		t.Run(fmt.Sprintf("%q one byte reads", input), func(t *testing.T) {
+			r := NewOneNewlineReader(iotest.OneByteReader(strings.NewReader(input)))
+			buf, err := ioutil.ReadAll(iotest.OneByteReader(r))
+			ensureErrorNil(t, err)
+			if got := string(buf); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		})
+		t.Run(fmt.Sprintf("%q data with EOF", input), func(t *testing.T) {
+			r := NewOneNewlineReader(iotest.DataErrReader(strings.NewReader(input)))
+			buf, err := ioutil.ReadAll(r)
+			ensureErrorNil(t, err)
+			if got := string(buf); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		})
+	}
+
+	t.Run("error other than EOF", func(t *testing.T) {
+		errTimeout := errors.New("timeout")
+		r := NewOneNewlineReader(&testReader{tuples: []tuple{
+			tuple{"abc\n\n", errTimeout},
+			tuple{"\ndef", io.EOF},
+		}})
+
+		buf, err := ioutil.ReadAll(r)
+		if err != errTimeout {
+			t.Fatalf("GOT: %v; WANT: %v", err, errTimeout)
+		}
+		if got, want := string(buf), "abc"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		buf, err = ioutil.ReadAll(r)
+		ensureErrorNil(t, err)
+		if got, want := string(buf), "\n\n\ndef\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("holds only trailing newlines", func(t *testing.T) {
+		r := NewOneNewlineReader(&testReader{tuples: []tuple{
+			tuple{"abc\n\n", nil},
+		}})
+		buf := make([]byte, 16)
+		n, err := r.Read(buf)
+		ensureErrorNil(t, err)
+		ensureBufferLimit(t, buf, n, "abc")
+	})
+}
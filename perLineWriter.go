@@ -2,30 +2,91 @@ package gonl
 
 import (
 	"bytes"
-	"errors"
+	"context"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // PerLineWriter is a synchronous io.WriteCloser which writes each
 // completed newline terminated line to the underlying io.WriteCloser.
 //
-// This stream processor ensures there is exactly one Write call made
-// to the underlying io.WriteCloser for each newline terminated line
-// being written to it.
+// By default, this stream processor makes one Write call to the
+// underlying io.WriteCloser for each newline terminated line written
+// to it, and never gives it more than one line at a time. That line
+// may take more than one Write call when the underlying io.WriteCloser
+// returns a short write without an error, in which case the remaining
+// bytes are written with additional calls. Also, the options change
+// what is written: IdleTimeout writes a partial line without a
+// newline, ProgressInterval writes replaced frames as lines of their
+// own, and Close writes a final line that is not newline terminated
+// according to FinalLine.
 //
 // Compare this structure with BatchLineWriter. This structure is
 // suitable for situations that require line buffering. This structure
 // is used to ensure each newline terminated line is individually sent
-// to the underlying io.WriteCloser. Calling its Write method only
-// invokes Write on the underlying io.WriteCloser with a newline
-// terminated sequence of bytes.
+// to the underlying io.WriteCloser, rather than being combined with
+// other lines.
 type PerLineWriter struct {
 	buf []byte
 
 	// WC is io.WriteCloser where data is ultimately written.
 	WC io.WriteCloser
 
+	// IdleTimeout, when greater than 0, causes a partial line that
+	// has remained in the buffer without any new bytes being written
+	// for this long to be written to WC as-is, so prompts that do not
+	// end with a newline are displayed. The remainder of that line is
+	// written when it arrives, without repeating the bytes already
+	// written. When this idle flush fails, the bytes not written
+	// remain buffered, and the failure is counted in Stats. The idle
+	// flush is driven by a timer of the time package, and always
+	// measures time with the system clock, regardless of Clock. It
+	// must be set before the first Write.
+	IdleTimeout time.Duration
+
+	// CollapseCarriageReturns, when true, treats each carriage return
+	// that is not followed by a newline as replacing the partial line
+	// before it, as a terminal displays the frames of a progress bar,
+	// so only the final frame of each line is written to WC. A
+	// carriage return followed by a newline is written as-is. It must
+	// be set before the first Write.
+	CollapseCarriageReturns bool
+
+	// ProgressInterval, when greater than 0 and
+	// CollapseCarriageReturns is true, causes a replaced frame to be
+	// written to WC as its own newline terminated line, but no more
+	// often than once per ProgressInterval.
+	ProgressInterval time.Duration
+
+	// Clock, when not nil, is used to throttle writing replaced
+	// frames. When nil, the system clock is used. Because the Clock
+	// interface cannot schedule a callback, it does not apply to
+	// IdleTimeout.
+	Clock Clock
+
+	// FinalLine determines what Close does with a final line that is
+	// not newline terminated. The zero value is FinalLineAsIs. It
+	// must be set before Close.
+	FinalLine FinalLinePolicy
+
 	off int // read at buf[off:]; write at buf[:len(buf)]
+
+	// rescan is true when buf[off:] might hold newline terminated
+	// lines that were not written because of a write error.
+	rescan bool
+
+	// activity statistics, also used to report write errors
+	stats counters
+
+	// mu serializes idle flushes with Write, ReadFrom, and Close.
+	mu        sync.Mutex
+	idle      *time.Timer // nil until IdleTimeout first armed
+	lastInput time.Time   // when bytes were last added to buf
+
+	lastProgress time.Time // when a replaced frame was last written
+	progress     []byte    // replaced frame followed by newline
 }
 
 // NewPerLineWriter returns a new PerLineWriter that individually
@@ -39,8 +100,9 @@ func NewPerLineWriter(wc io.WriteCloser) *PerLineWriter {
 // bufferGrow will ensure the backing buffer has enough room to hold
 // at least n more bytes, reslicing the data in the buffer if
 // possible, and expanding the backing array if necessary. It returns
-// the index into the buffer where bytes may be added.
-func (lw *PerLineWriter) bufferGrow(n int) int {
+// the index into the buffer where bytes may be added, or
+// ErrBufferOverflow when the backing array cannot grow large enough.
+func (lw *PerLineWriter) bufferGrow(n int) (int, error) {
 	m := lw.bufferLength()
 	if m == 0 && lw.off != 0 {
 		// Reset buffer to reduce likelihood of unnecessary
@@ -50,14 +112,14 @@ func (lw *PerLineWriter) bufferGrow(n int) int {
 	if i, ok := lw.bufferGrowInline(n); ok {
 		// NOTE: This is the only way to exit this method with lw.off
 		// potentially not being set to 0.
-		return i
+		return i, nil
 	}
 	// NOTE: If we get here, there is no way of leaving this method
 	// without lw.off set to 0, and any used portion of buffer moved
 	// to the left.
 	if lw.buf == nil && n <= smallBufferSize {
 		lw.buf = make([]byte, n, smallBufferSize)
-		return 0
+		return 0, nil
 	}
 	mpn := m + n
 	c := cap(lw.buf)
@@ -67,7 +129,7 @@ func (lw *PerLineWriter) bufferGrow(n int) int {
 		// byte copying.
 		copy(lw.buf, lw.buf[lw.off:])
 	} else if c > maxInt-c-n {
-		panic(errors.New("gonl.PerLineWriter: too large"))
+		return 0, ErrBufferOverflow
 	} else {
 		// Allocate new backing array, then copy bytes.
 		buf := make([]byte, 2*c+n)
@@ -76,7 +138,7 @@ func (lw *PerLineWriter) bufferGrow(n int) int {
 	}
 	lw.off = 0
 	lw.buf = lw.buf[:mpn]
-	return m
+	return m, nil
 }
 
 // bufferGrowInline is an inlineable version of grow for the fast case
@@ -105,27 +167,56 @@ func (lw *PerLineWriter) bufferReset() {
 }
 
 // Close will transform then write any data remaining in the
-// PerLineWriter that was not newline terminated, then closes the
-// underlying io.WriteCloser.
+// PerLineWriter that was not newline terminated, handled according to
+// FinalLine, then closes the underlying io.WriteCloser. Any completed
+// lines that remain buffered because of a previous write error are
+// written first, each with its own Write call. For the FinalLineError
+// policy, it returns an *UnterminatedLineError after closing the
+// underlying io.WriteCloser. Invoking Close more than once has no
+// effect and returns nil.
 func (lw *PerLineWriter) Close() error {
-	var err error
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	if lw.WC == nil {
+		return nil // already closed
+	}
+	if lw.idle != nil {
+		lw.idle.Stop()
+	}
 
-	if lw.bufferLength() > 0 {
+	_, err := lw.writeLines(lw.off)
+
+	var ferr error
+	if err == nil {
 		// When additional bytes are available to be written, flush
-		// them without a newline before we close the stream.
-		if _, err = lw.WC.Write(lw.buf[lw.off:]); err != nil {
-			_ = lw.WC.Close()
-			lw.WC = nil
-			lw.buf = nil
-			lw.off = 0
-			return err
+		// them before we close the stream.
+		var tail []byte
+		line := atomic.LoadInt64(&lw.stats.lines) + 1
+		tail, ferr = finalLine(lw.FinalLine, lw.buf[lw.off:], lw.stats.midLine, line)
+		if len(tail) > 0 {
+			atomic.AddInt64(&lw.stats.closeFlushes, 1)
+			_, err = lw.stats.writeAll(lw.WC, tail)
 		}
 	}
 
-	err = lw.WC.Close()
+	if err != nil {
+		_ = lw.WC.Close()
+		lw.WC = nil
+		lw.buf = nil
+		lw.off = 0
+		lw.rescan = false
+		return err
+	}
+
+	err = lw.stats.failed(lw.WC.Close())
 	lw.WC = nil
 	lw.buf = nil
 	lw.off = 0
+	lw.rescan = false
+	if ferr != nil {
+		return ferr
+	}
 	return err
 }
 
@@ -135,43 +226,75 @@ func (lw *PerLineWriter) Close() error {
 // except io.EOF encountered during the read or during a flushing
 // Write is also returned.
 //
+// Because the bytes read from r cannot be returned to it, when
+// writing to the underlying io.WriteCloser fails, the bytes that were
+// not written remain buffered, and will be written by a later Write,
+// ReadFrom, or Close.
+//
 // This method is provided to satisfy the io.ReaderFrom interface,
 // which the io.Copy function uses if available, eliminating the need
 // to copy bytes from the io.Reader, through two buffers, and finally
 // to the io.Writer.
 func (lw *PerLineWriter) ReadFrom(r io.Reader) (int64, error) {
+	return lw.ReadFromContext(context.Background(), r)
+}
+
+// ReadFromContext is like ReadFrom, but checks whether ctx is done
+// before each read from r. When ctx is done, it writes any completed
+// lines that remain buffered because of a previous write error to the
+// underlying io.WriteCloser, then returns the number of bytes read
+// from r along with ctx.Err(). Any bytes after the final newline
+// remain buffered. This allows long running copies from pipes and
+// sockets to be stopped cleanly, provided the Read calls on r
+// eventually return.
+func (lw *PerLineWriter) ReadFromContext(ctx context.Context, r io.Reader) (int64, error) {
 	var totalRead int64
 
+	if lw.IdleTimeout > 0 || lw.CollapseCarriageReturns {
+		return lw.readFromCopy(ctx, r)
+	}
+
+	if lw.WC == nil {
+		return 0, ErrClosed
+	}
+
 	for {
-		m := lw.bufferGrow(minRead)
+		if err := ctx.Err(); err != nil {
+			if lw.rescan {
+				if _, werr := lw.writeLines(lw.off); werr != nil {
+					return totalRead, werr
+				}
+				lw.rescan = false
+			}
+			return totalRead, err
+		}
+
+		m, err := lw.bufferGrow(minRead)
+		if err != nil {
+			return totalRead, err
+		}
 		lw.buf = lw.buf[:m]
 
 		nr, rerr := r.Read(lw.buf[m:cap(lw.buf)])
-		if nr < 0 {
-			return totalRead, errors.New("invalid read result")
+		if nr < 0 || nr > cap(lw.buf)-m {
+			return totalRead, ErrInvalidRead
 		}
 
 		lw.buf = lw.buf[:m+nr]
 		totalRead += int64(nr)
+		atomic.AddInt64(&lw.stats.bytesIn, int64(nr))
+		lw.stats.buffered(lw.bufferLength())
 
 		// NEWLINE LOGIC
 		//
-		index := bytes.IndexByte(lw.buf[m:], '\n')
-		if index >= 0 {
-			// POST: lw.buf[m+index] is a newline.
-			index += m + 1 // extra byte to include newline
-			for {
-				if _, err := lw.WC.Write(lw.buf[lw.off:index]); err != nil {
-					return totalRead, err // ???
-				}
-				lw.off = index // advance buf to consume bytes processed
-
-				index = bytes.IndexByte(lw.buf[lw.off:], '\n')
-				if index == -1 {
-					break
-				}
-				index += lw.off + 1 // extra byte to include newline
-			}
+		search := m
+		if lw.rescan {
+			search = lw.off
+			lw.rescan = false
+		}
+		if _, err := lw.writeLines(search); err != nil {
+			lw.rescan = true
+			return totalRead, err
 		}
 		//
 		// END OF NEWLINE LOGIC
@@ -189,40 +312,305 @@ func (lw *PerLineWriter) ReadFrom(r io.Reader) (int64, error) {
 	}
 }
 
+// readFromCopy is like ReadFromContext, but reads into a separate
+// buffer without holding the lock, so idle flushes may happen while
+// waiting for r, then appends what it reads to the buffer with
+// appendLines, so carriage returns may be collapsed.
+func (lw *PerLineWriter) readFromCopy(ctx context.Context, r io.Reader) (int64, error) {
+	var totalRead int64
+
+	buf := make([]byte, copyBufferSize)
+
+	for {
+		lw.mu.Lock()
+		if lw.WC == nil {
+			lw.mu.Unlock()
+			return totalRead, ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			if lw.rescan {
+				if _, werr := lw.writeLines(lw.off); werr != nil {
+					lw.mu.Unlock()
+					return totalRead, werr
+				}
+				lw.rescan = false
+			}
+			lw.mu.Unlock()
+			return totalRead, err
+		}
+		lw.mu.Unlock()
+
+		nr, rerr := r.Read(buf)
+		if nr < 0 || nr > len(buf) {
+			return totalRead, ErrInvalidRead
+		}
+		totalRead += int64(nr)
+
+		if nr > 0 {
+			lw.mu.Lock()
+			_, werr := lw.appendLines(buf[:nr])
+			lw.mu.Unlock()
+			if werr != nil {
+				return totalRead, werr
+			}
+		}
+
+		if rerr == io.EOF {
+			return totalRead, nil
+		}
+		if rerr != nil {
+			return totalRead, rerr
+		}
+	}
+}
+
+// appendLines appends p to the buffer, then writes each completed
+// line. Unlike write, when writing fails, the bytes not written remain
+// buffered, so it returns len(p) once p has been appended.
+func (lw *PerLineWriter) appendLines(p []byte) (int, error) {
+	if lw.WC == nil {
+		return 0, ErrClosed
+	}
+
+	m, ok := lw.bufferGrowInline(len(p))
+	if !ok {
+		var err error
+		if m, err = lw.bufferGrow(len(p)); err != nil {
+			return 0, err
+		}
+	}
+	copy(lw.buf[m:], p)
+	atomic.AddInt64(&lw.stats.bytesIn, int64(len(p)))
+	lw.stats.buffered(lw.bufferLength())
+
+	search := m
+	if lw.CollapseCarriageReturns {
+		search = lw.collapseFrames(m)
+	}
+	if lw.rescan {
+		search = lw.off
+		lw.rescan = false
+	}
+	_, err := lw.writeLines(search)
+	if err != nil {
+		lw.rescan = true
+	}
+	lw.armIdle()
+	return len(p), err
+}
+
+// collapseFrames removes each frame in buf that is followed by a
+// carriage return that is not followed by a newline, beginning with
+// the bytes added at index from. It returns the index from which buf
+// must be searched for newlines. A carriage return at the end of buf
+// remains until the following byte arrives.
+func (lw *PerLineWriter) collapseFrames(from int) int {
+	search := from
+	if from > lw.off && lw.buf[from-1] == '\r' {
+		from-- // carriage return from previous write
+	}
+	for {
+		i := bytes.IndexByte(lw.buf[from:], '\r')
+		if i == -1 {
+			return search
+		}
+		i += from
+		if i+1 == len(lw.buf) {
+			return search // cannot tell whether newline follows
+		}
+		if lw.buf[i+1] == '\n' {
+			from = i + 2
+			continue
+		}
+
+		// Frame begins after the final newline before i.
+		start := lw.off + bytes.LastIndexByte(lw.buf[lw.off:i], '\n') + 1
+		lw.writeProgress(lw.buf[start:i])
+		n := copy(lw.buf[start:], lw.buf[i+1:])
+		lw.buf = lw.buf[:start+n]
+		if start < search {
+			search = start
+		}
+		from = start
+	}
+}
+
+// writeProgress writes a replaced frame to WC as a newline terminated
+// line when ProgressInterval has elapsed since the previous one. Like
+// idle flushes, write errors are only counted in Stats.
+func (lw *PerLineWriter) writeProgress(frame []byte) {
+	if lw.ProgressInterval <= 0 || len(frame) == 0 {
+		return
+	}
+	clock := lw.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	now := clock.Now()
+	if !lw.lastProgress.IsZero() && now.Sub(lw.lastProgress) < lw.ProgressInterval {
+		return
+	}
+	lw.lastProgress = now
+	lw.progress = append(append(lw.progress[:0], frame...), '\n')
+	atomic.AddInt64(&lw.stats.flushes, 1)
+	_, _ = lw.stats.writeAll(lw.WC, lw.progress)
+}
+
+// armIdle starts or restarts the idle timer when IdleTimeout is
+// greater than 0 and a partial line is buffered.
+func (lw *PerLineWriter) armIdle() {
+	if lw.IdleTimeout <= 0 || lw.bufferLength() == 0 {
+		return
+	}
+	lw.lastInput = time.Now()
+	if lw.idle == nil {
+		lw.idle = time.AfterFunc(lw.IdleTimeout, lw.idleFlush)
+		return
+	}
+	lw.idle.Reset(lw.IdleTimeout)
+}
+
+// idleFlush is invoked by the idle timer, and writes the partial line
+// in the buffer to WC when no bytes have been added to the buffer for
+// IdleTimeout.
+func (lw *PerLineWriter) idleFlush() {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	if lw.WC == nil || lw.bufferLength() == 0 {
+		return
+	}
+	if d := lw.IdleTimeout - time.Since(lw.lastInput); d > 0 {
+		// Bytes were added after this timer fired, but before it
+		// acquired the lock.
+		lw.idle.Reset(d)
+		return
+	}
+
+	if lw.rescan {
+		// Completed lines remain from a previous write error.
+		if _, err := lw.writeLines(lw.off); err != nil {
+			return
+		}
+		lw.rescan = false
+	}
+	if lw.bufferLength() == 0 {
+		return
+	}
+
+	atomic.AddInt64(&lw.stats.flushes, 1)
+	nw, _ := lw.stats.writeAll(lw.WC, lw.buf[lw.off:])
+	lw.off += nw
+}
+
 // Write invokes Write on the underlying io.WriteCloser for each
 // newline terminated sequence of bytes in p. Each call to this method
 // may result in 0, 1, or many Write calls to the underlying
 // io.WriteCloser, depending on how many newline characters are in p.
+//
+// When a Write to the underlying io.WriteCloser fails, this returns
+// the number of bytes from p that were delivered to the underlying
+// io.WriteCloser, along with the error. When the failure occurred
+// before all the bytes buffered by previous calls were delivered, it
+// returns 0, and the undelivered bytes from previous calls remain
+// buffered, so a later Write or Close may retry them. The caller
+// remains responsible for the bytes from p that were not delivered.
+//
+// When CollapseCarriageReturns is true, because the bytes written to
+// the underlying io.WriteCloser no longer correspond to the bytes of
+// p, it instead returns len(p) along with the error, and the bytes not
+// delivered remain buffered.
+//
+// It returns ErrClosed after the PerLineWriter has been closed.
 func (lw *PerLineWriter) Write(p []byte) (int, error) {
-	var err error
-	var index int
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	if lw.CollapseCarriageReturns {
+		return lw.appendLines(p)
+	}
+
+	n, err := lw.write(p)
+	lw.armIdle()
+	return n, err
+}
+
+// write is Write without the lock.
+func (lw *PerLineWriter) write(p []byte) (int, error) {
+	if lw.WC == nil {
+		return 0, ErrClosed
+	}
+
+	leno := lw.bufferLength()
 
 	m, ok := lw.bufferGrowInline(len(p))
 	if !ok {
-		m = lw.bufferGrow(len(p))
+		var err error
+		if m, err = lw.bufferGrow(len(p)); err != nil {
+			return 0, err
+		}
 	}
 	copy(lw.buf[m:], p)
+	lw.stats.buffered(lw.bufferLength())
 	// POST: lw.buf[m:] is new data, however lw.buf[lw.off:m] also
 	// needs processing.
 
-	// We know remaining bytes lw.buf[lw.off:m] does not have a
-	// newline, so start searching at offset m.
-	index = bytes.IndexByte(lw.buf[m:], '\n')
-	if index == -1 {
+	// Unless a previous write error left completed lines in the
+	// buffer, we know remaining bytes lw.buf[lw.off:m] does not have
+	// a newline, so start searching at offset m.
+	search := m
+	if lw.rescan {
+		search = lw.off
+		lw.rescan = false
+	}
+
+	nd, err := lw.writeLines(search)
+	if err == nil {
+		atomic.AddInt64(&lw.stats.bytesIn, int64(len(p)))
 		return len(p), nil
 	}
-	// POST: lw.buf[m+index] is a newline.
-	index += m + 1 // extra byte to include newline
 
+	// nb is the number of new bytes from p that were delivered.
+	if nb := nd - leno; nb >= 0 {
+		// Delivered all bytes buffered before this call, and nb bytes
+		// of p. Caller is responsible for the remaining bytes of p, so
+		// use the opportunity to reset buffer.
+		lw.bufferReset()
+		atomic.AddInt64(&lw.stats.bytesIn, int64(nb))
+		return nb, err
+	}
+
+	// Did not deliver all bytes buffered before this call. Keep the
+	// undelivered ones, but none of p.
+	lw.buf = lw.buf[:m]
+	lw.rescan = true
+	return 0, err
+}
+
+// Stats returns a snapshot of the activity of the PerLineWriter since
+// it was created. It is safe to invoke from any goroutine, even while
+// another goroutine is writing to the PerLineWriter.
+func (lw *PerLineWriter) Stats() LineWriterStats { return lw.stats.snapshot() }
+
+// writeLines writes each newline terminated line in buf[off:] to the
+// underlying io.WriteCloser, with one Write call per line, advancing
+// off past each byte delivered. It begins searching for newlines at
+// the specified index, and returns the number of bytes delivered.
+func (lw *PerLineWriter) writeLines(index int) (int, error) {
+	var nd int
 	for {
-		if _, err = lw.WC.Write(lw.buf[lw.off:index]); err != nil {
-			return len(p), err // ???
+		i := bytes.IndexByte(lw.buf[index:], '\n')
+		if i == -1 {
+			return nd, nil
 		}
-		lw.off = index // advance buf to consume bytes processed
-		index = bytes.IndexByte(lw.buf[lw.off:], '\n')
-		if index == -1 {
-			return len(p), nil
+		index += i + 1 // extra byte to include newline
+
+		atomic.AddInt64(&lw.stats.flushes, 1)
+		nw, err := lw.stats.writeAll(lw.WC, lw.buf[lw.off:index])
+		nd += nw
+		lw.off += nw // advance buf to consume bytes processed
+		if err != nil {
+			return nd, err
 		}
-		index += lw.off + 1 // extra byte to include newline
 	}
 }
@@ -2,7 +2,12 @@ package gonl
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPerLineWriter(t *testing.T) {
@@ -126,4 +131,301 @@ func TestPerLineWriter(t *testing.T) {
 			drain.Reset()
 		})
 	})
+
+	t.Run("short write", func(t *testing.T) {
+		t.Run("Write", func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewPerLineWriter(NopCloseWriter(trickleWriter{Writer: output, max: 3}))
+			ensureWrite(t, lw, "line 1\nline 2\nline 3")
+			ensureStringer(t, output, "line 1\nline 2\n")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "line 1\nline 2\nline 3")
+		})
+
+		t.Run("ReadFrom", func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewPerLineWriter(NopCloseWriter(trickleWriter{Writer: output, max: 3}))
+			r := &testReader{tuples: []tuple{
+				tuple{"line 1\nline 2\n", nil},
+				tuple{"line 3", io.EOF},
+			}}
+			nr, err := lw.ReadFrom(r)
+			ensureErrorNil(t, err)
+			if got, want := nr, int64(20); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureStringer(t, output, "line 1\nline 2\n")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "line 1\nline 2\nline 3")
+		})
+
+		t.Run("no progress", func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewPerLineWriter(NopCloseWriter(trickleWriter{Writer: output, max: 0}))
+			_, err := lw.Write([]byte("line 1\n"))
+			ensureError(t, err, io.ErrShortWrite.Error())
+		})
+
+		t.Run("error", func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewPerLineWriter(NopCloseWriter(ShortWriter(output, 4)))
+			_, err := lw.Write([]byte("line 1\n"))
+			ensureError(t, err, io.ErrShortWrite.Error())
+			ensureStringer(t, output, "line")
+		})
+
+		t.Run("Close", func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewPerLineWriter(NopCloseWriter(trickleWriter{Writer: output, max: 3}))
+			ensureWrite(t, lw, "line 1")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "line 1")
+		})
+	})
+
+	t.Run("write error accounting", func(t *testing.T) {
+		errTransient := errors.New("transient")
+
+		t.Run("error after buffered bytes delivered", func(t *testing.T) {
+			output := &flakyWriteCloser{results: []flakyResult{
+				{7, nil},
+				{2, errTransient},
+			}}
+			lw := NewPerLineWriter(output)
+			ensureWrite(t, lw, "li")
+
+			p := "ne 1\nline 2\nline 3"
+			n, err := lw.Write([]byte(p))
+			ensureError(t, err, "transient")
+			if got, want := n, 7; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureStringer(t, output, "line 1\nli")
+
+			// Caller retries the bytes that were not delivered.
+			ensureWrite(t, lw, p[n:])
+			ensureStringer(t, output, "line 1\nline 2\n")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "line 1\nline 2\nline 3")
+		})
+
+		t.Run("error before buffered bytes delivered", func(t *testing.T) {
+			output := &flakyWriteCloser{results: []flakyResult{
+				{2, errTransient},
+			}}
+			lw := NewPerLineWriter(output)
+			ensureWrite(t, lw, "line")
+
+			p := " 1\nline 2\n"
+			n, err := lw.Write([]byte(p))
+			ensureError(t, err, "transient")
+			if got, want := n, 0; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureStringer(t, output, "li")
+
+			// Undelivered bytes from previous call remain buffered.
+			ensureWrite(t, lw, p)
+			ensureStringer(t, output, "line 1\nline 2\n")
+			ensureErrorNil(t, lw.Close())
+		})
+
+		t.Run("ReadFrom retains lines", func(t *testing.T) {
+			output := &flakyWriteCloser{results: []flakyResult{
+				{0, errTransient},
+			}}
+			lw := NewPerLineWriter(output)
+			r := &testReader{tuples: []tuple{
+				tuple{"line 1\nline 2\nline 3", io.EOF},
+			}}
+
+			nr, err := lw.ReadFrom(r)
+			ensureError(t, err, "transient")
+			if got, want := nr, int64(20); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureStringer(t, output, "")
+
+			// Retained lines are still written one per Write call.
+			ensureWrite(t, lw, "\n")
+			ensureStringer(t, output, "line 1\nline 2\nline 3\n")
+			if got, want := output.calls, 4; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureErrorNil(t, lw.Close())
+		})
+	})
+
+	t.Run("ReadFromContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		r := &cancelingReader{
+			Reader: &testReader{tuples: []tuple{
+				tuple{"line 1\n", nil},
+				tuple{"line 2\nline 3", nil},
+			}},
+			cancel: cancel,
+			reads:  2,
+		}
+
+		output := new(testBuffer)
+		lw := NewPerLineWriter(output)
+
+		nr, err := lw.ReadFromContext(ctx, r)
+		if got, want := err, context.Canceled; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := nr, int64(20); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStringer(t, output, "line 1\nline 2\n")
+		ensureErrorNil(t, lw.Close())
+		ensureStringer(t, output, "line 1\nline 2\nline 3")
+	})
+
+	t.Run("IdleTimeout", func(t *testing.T) {
+		ensureWritten := func(t *testing.T, output *signalingWriteCloser, want string) {
+			t.Helper()
+			select {
+			case got := <-output.written:
+				if got != want {
+					t.Errorf("GOT: %q; WANT: %q", got, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("GOT: timeout; WANT: %q", want)
+			}
+		}
+
+		t.Run("Write", func(t *testing.T) {
+			output := newSignalingWriteCloser()
+			lw := NewPerLineWriter(output)
+			lw.IdleTimeout = 10 * time.Millisecond
+
+			ensureWrite(t, lw, "Password: ")
+			ensureWritten(t, output, "Password: ")
+
+			// Remainder of line is written without repeating prompt.
+			ensureWrite(t, lw, "secret\n")
+			ensureWritten(t, output, "secret\n")
+
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "Password: secret\n")
+		})
+
+		t.Run("completed lines", func(t *testing.T) {
+			output := newSignalingWriteCloser()
+			lw := NewPerLineWriter(output)
+			lw.IdleTimeout = time.Millisecond
+
+			ensureWrite(t, lw, "line 1\n")
+			ensureWritten(t, output, "line 1\n")
+
+			time.Sleep(20 * time.Millisecond)
+			ensureErrorNil(t, lw.Close())
+			if got, want := len(output.written), 0; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("ReadFrom", func(t *testing.T) {
+			output := newSignalingWriteCloser()
+			lw := NewPerLineWriter(output)
+			lw.IdleTimeout = 10 * time.Millisecond
+
+			pr, pw := io.Pipe()
+			done := make(chan error, 1)
+			go func() {
+				_, err := lw.ReadFrom(pr)
+				done <- err
+			}()
+
+			_, err := pw.Write([]byte("Continue? [y/N] "))
+			ensureErrorNil(t, err)
+			ensureWritten(t, output, "Continue? [y/N] ")
+
+			_, err = pw.Write([]byte("y\n"))
+			ensureErrorNil(t, err)
+			ensureErrorNil(t, pw.Close())
+			ensureErrorNil(t, <-done)
+
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "Continue? [y/N] y\n")
+		})
+	})
+
+	t.Run("CollapseCarriageReturns", func(t *testing.T) {
+		t.Run("keeps final frame", func(t *testing.T) {
+			output := newSignalingWriteCloser()
+			lw := NewPerLineWriter(output)
+			lw.CollapseCarriageReturns = true
+
+			ensureWrite(t, lw, "Downloading\n 10%\r 50%\r100%\n")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "Downloading\n100%\n")
+			if got, want := len(output.written), 2; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("carriage return before newline", func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewPerLineWriter(output)
+			lw.CollapseCarriageReturns = true
+
+			ensureWrite(t, lw, "line 1\r\nline 2\r")
+			ensureWrite(t, lw, "\n10%\r")
+			ensureWrite(t, lw, "20%\r")
+			ensureWrite(t, lw, "done\n")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "line 1\r\nline 2\r\ndone\n")
+		})
+
+		t.Run("ProgressInterval", func(t *testing.T) {
+			clock := newTestClock()
+			output := new(testBuffer)
+			lw := NewPerLineWriter(output)
+			lw.CollapseCarriageReturns = true
+			lw.ProgressInterval = time.Second
+			lw.Clock = clock
+
+			ensureWrite(t, lw, "1%\r2%\r")
+			clock.Advance(time.Second)
+			ensureWrite(t, lw, "3%\r4%\r")
+			ensureWrite(t, lw, "done\n")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "1%\n2%\ndone\n")
+		})
+
+		t.Run("ReadFrom", func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewPerLineWriter(output)
+			lw.CollapseCarriageReturns = true
+
+			nr, err := lw.ReadFrom(strings.NewReader("a\rb\rc\nd"))
+			ensureErrorNil(t, err)
+			if got, want := nr, int64(7); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "c\nd")
+		})
+	})
+}
+
+// signalingWriteCloser is an io.WriteCloser that sends a copy of the
+// bytes from each Write call on its written channel.
+type signalingWriteCloser struct {
+	testBuffer
+	written chan string
+}
+
+func newSignalingWriteCloser() *signalingWriteCloser {
+	return &signalingWriteCloser{written: make(chan string, 64)}
+}
+
+func (s *signalingWriteCloser) Write(p []byte) (int, error) {
+	n, err := s.testBuffer.Write(p)
+	s.written <- string(p[:n])
+	return n, err
 }
@@ -0,0 +1,222 @@
+package gonl
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// RateLimitedLineWriter is an io.WriteCloser that limits the rate at
+// which completed lines are written to the underlying io.WriteCloser,
+// using a token bucket for the number of lines per second, and
+// another for the number of bytes per second. Each bucket holds up to
+// one second worth of tokens, allowing short bursts.
+//
+// When a line would exceed either budget, it is either dropped in its
+// entirety, or, when Block is true, the Write call waits until the
+// budget allows the line to be written. Lines are never partially
+// written. When lines have been dropped, a notice reporting how many
+// were dropped is written to the underlying io.WriteCloser prior to
+// the next line that is written, but no more often than
+// NoticeInterval, and when the RateLimitedLineWriter is closed.
+// Notices are not subject to the rate limits.
+//
+// It is important for caller to Close the RateLimitedLineWriter to
+// flush any residual data that was not terminated with a newline.
+type RateLimitedLineWriter struct {
+	lb lineBuffer
+	wc io.WriteCloser
+
+	// Block causes Write to wait until a line is within budget rather
+	// than dropping it.
+	Block bool
+
+	// NoticeInterval is the minimum amount of time between notices
+	// reporting dropped lines.
+	NoticeInterval time.Duration
+
+	// Clock, when not nil, is used to measure elapsed time and to
+	// wait for a budget to refill. When nil, the system clock is
+	// used.
+	Clock Clock
+
+	lines, bytes tokenBucket
+
+	last       time.Time // when buckets were last refilled
+	lastNotice time.Time // when notice was last written
+	pending    uint64    // lines dropped since last notice
+	dropped    uint64    // lines dropped since created
+	midLine    bool      // final byte written was not a newline
+}
+
+// NewRateLimitedLineWriter returns a new RateLimitedLineWriter that
+// writes at most linesPerSecond lines and bytesPerSecond bytes to the
+// provided io.WriteCloser. Either limit may be 0 to disable it, but
+// not both.
+func NewRateLimitedLineWriter(wc io.WriteCloser, linesPerSecond, bytesPerSecond float64) (*RateLimitedLineWriter, error) {
+	if linesPerSecond < 0 || bytesPerSecond < 0 {
+		return nil, &ConfigError{Type: "RateLimitedLineWriter", Reason: fmt.Sprintf("when a rate is less than 0: %v lines per second; %v bytes per second", linesPerSecond, bytesPerSecond)}
+	}
+	if linesPerSecond == 0 && bytesPerSecond == 0 {
+		return nil, &ConfigError{Type: "RateLimitedLineWriter", Reason: "when both rates are 0"}
+	}
+	return &RateLimitedLineWriter{
+		wc:    wc,
+		lines: tokenBucket{rate: linesPerSecond, tokens: linesPerSecond},
+		bytes: tokenBucket{rate: bytesPerSecond, tokens: bytesPerSecond},
+	}, nil
+}
+
+// Close flushes any buffered data that was not terminated with a
+// newline, subject to the same rate limits as other lines, writes a
+// final notice when lines have been dropped since the previous
+// notice, then closes the underlying io.WriteCloser. Invoking Close
+// more than once has no effect and returns nil.
+func (lw *RateLimitedLineWriter) Close() error {
+	if lw.wc == nil {
+		return nil // already closed
+	}
+	err := lw.lb.flush(lw.limit)
+	if err == nil && lw.pending > 0 {
+		err = lw.notice(lw.clock().Now())
+	}
+	if err != nil {
+		_ = lw.wc.Close()
+		lw.wc = nil
+		return err
+	}
+	err = lw.wc.Close()
+	lw.wc = nil
+	return err
+}
+
+// Dropped returns the number of lines dropped since the
+// RateLimitedLineWriter was created.
+func (lw *RateLimitedLineWriter) Dropped() uint64 { return lw.dropped }
+
+func (lw *RateLimitedLineWriter) clock() Clock {
+	if lw.Clock == nil {
+		return systemClock{}
+	}
+	return lw.Clock
+}
+
+// limit either writes line to the underlying io.WriteCloser, or drops
+// it, waiting for the budget to allow it when Block is true.
+func (lw *RateLimitedLineWriter) limit(line []byte) error {
+	clock := lw.clock()
+	now := lw.refill(clock.Now())
+	n := float64(len(line))
+
+	for {
+		d := lw.lines.wait(1)
+		if bd := lw.bytes.wait(n); bd > d {
+			d = bd
+		}
+		if d == 0 {
+			break
+		}
+		if !lw.Block {
+			lw.pending++
+			lw.dropped++
+			return nil
+		}
+		clock.Sleep(d)
+		now = lw.refill(clock.Now())
+	}
+
+	if lw.pending > 0 && now.Sub(lw.lastNotice) >= lw.NoticeInterval {
+		if err := lw.notice(now); err != nil {
+			return err
+		}
+	}
+	if _, err := writeAll(lw.wc, line); err != nil {
+		return err
+	}
+	lw.midLine = line[len(line)-1] != '\n'
+	lw.lines.take(1)
+	lw.bytes.take(n)
+	return nil
+}
+
+// notice writes a line to the underlying io.WriteCloser reporting the
+// number of lines dropped since the previous notice, preceded by a
+// newline when the final line written was not newline terminated, so
+// the notice remains on its own line.
+func (lw *RateLimitedLineWriter) notice(now time.Time) error {
+	var prefix string
+	if lw.midLine {
+		prefix = "\n"
+	}
+	if _, err := writeAll(lw.wc, []byte(fmt.Sprintf("%sgonl: dropped %d lines\n", prefix, lw.pending))); err != nil {
+		return err
+	}
+	lw.midLine = false
+	lw.pending = 0
+	lw.lastNotice = now
+	return nil
+}
+
+// refill adds tokens to both buckets for the time that has elapsed
+// since they were last refilled, and returns now.
+func (lw *RateLimitedLineWriter) refill(now time.Time) time.Time {
+	if !lw.last.IsZero() {
+		elapsed := now.Sub(lw.last)
+		lw.lines.refill(elapsed)
+		lw.bytes.refill(elapsed)
+	}
+	lw.last = now
+	return now
+}
+
+// Write buffers p, and for each completed line, either writes it to
+// the underlying io.WriteCloser when it is within budget, or drops
+// it, or waits for the budget to allow it when Block is true. It
+// returns ErrClosed after the RateLimitedLineWriter has been closed.
+func (lw *RateLimitedLineWriter) Write(p []byte) (int, error) {
+	if lw.wc == nil {
+		return 0, ErrClosed
+	}
+	return lw.lb.write(p, lw.limit)
+}
+
+// tokenBucket holds up to one second worth of tokens, which
+// accumulate at rate tokens per second. A rate of 0 means the bucket
+// never limits anything.
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+}
+
+func (b *tokenBucket) refill(elapsed time.Duration) {
+	if b.rate == 0 || elapsed <= 0 {
+		return
+	}
+	if b.tokens += elapsed.Seconds() * b.rate; b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.rate != 0 {
+		b.tokens -= n
+	}
+}
+
+// wait returns how long until the bucket holds enough tokens to take
+// n of them. Because a bucket never holds more than one second worth
+// of tokens, a full bucket allows any request, possibly going into
+// debt that must be repaid before the next request is allowed.
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if b.rate == 0 {
+		return 0
+	}
+	if n > b.rate {
+		n = b.rate
+	}
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration(math.Ceil((n - b.tokens) / b.rate * float64(time.Second)))
+}
@@ -0,0 +1,125 @@
+package gonl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitedLineWriter(t *testing.T) {
+	t.Run("NewRateLimitedLineWriter", func(t *testing.T) {
+		_, err := NewRateLimitedLineWriter(new(discardWriteCloser), -1, 0)
+		ensureError(t, err, "less than 0")
+
+		_, err = NewRateLimitedLineWriter(new(discardWriteCloser), 0, 0)
+		ensureError(t, err, "both rates are 0")
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		t.Run("lines per second", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewRateLimitedLineWriter(output, 2, 0)
+			ensureErrorNil(t, err)
+			clock := newTestClock()
+			lw.Clock = clock
+
+			ensureWrite(t, lw, "line 1\nline 2\nline 3\nline 4\n")
+			ensureStringer(t, output, "line 1\nline 2\n")
+
+			clock.Advance(500 * time.Millisecond)
+			ensureWrite(t, lw, "line 5\nline 6\n")
+			ensureStringer(t, output, "line 1\nline 2\ngonl: dropped 2 lines\nline 5\n")
+
+			ensureWrite(t, lw, "line 7")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "line 1\nline 2\ngonl: dropped 2 lines\nline 5\ngonl: dropped 2 lines\n")
+
+			if got, want := lw.Dropped(), uint64(4); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("bytes per second", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewRateLimitedLineWriter(output, 0, 10)
+			ensureErrorNil(t, err)
+			lw.Clock = newTestClock()
+
+			// Line longer than bucket capacity permitted when bucket
+			// is full, but then no more lines until debt is repaid.
+			ensureWrite(t, lw, "this line is long\nshort\n")
+			ensureStringer(t, output, "this line is long\n")
+			if got, want := lw.Dropped(), uint64(1); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("notice interval", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewRateLimitedLineWriter(output, 1, 0)
+			ensureErrorNil(t, err)
+			clock := newTestClock()
+			lw.Clock = clock
+			lw.NoticeInterval = 10 * time.Second
+
+			for i := 0; i < 3; i++ {
+				ensureWrite(t, lw, "kept\ndropped\n")
+				clock.Advance(time.Second)
+			}
+			ensureStringer(t, output, "kept\ngonl: dropped 1 lines\nkept\nkept\n")
+
+			clock.Advance(10 * time.Second)
+			ensureWrite(t, lw, "kept\n")
+			ensureStringer(t, output, "kept\ngonl: dropped 1 lines\nkept\nkept\ngonl: dropped 2 lines\nkept\n")
+		})
+
+		t.Run("notice after unterminated final line", func(t *testing.T) {
+			output := new(testBuffer)
+			lw, err := NewRateLimitedLineWriter(output, 1, 0)
+			ensureErrorNil(t, err)
+			clock := newTestClock()
+			lw.Clock = clock
+			lw.NoticeInterval = 10 * time.Second
+
+			for i := 0; i < 2; i++ {
+				ensureWrite(t, lw, "kept\ndropped\n")
+				clock.Advance(time.Second)
+			}
+			ensureWrite(t, lw, "last")
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, "kept\ngonl: dropped 1 lines\nkept\nlast\ngonl: dropped 1 lines\n")
+		})
+	})
+
+	t.Run("block", func(t *testing.T) {
+		output := new(testBuffer)
+		lw, err := NewRateLimitedLineWriter(output, 2, 0)
+		ensureErrorNil(t, err)
+		clock := newTestClock()
+		lw.Clock = clock
+		lw.Block = true
+
+		ensureWrite(t, lw, "line 1\nline 2\nline 3\nline 4\nline 5")
+		ensureErrorNil(t, lw.Close())
+
+		ensureStringer(t, output, "line 1\nline 2\nline 3\nline 4\nline 5")
+		if got, want := clock.slept, 1500*time.Millisecond; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := lw.Dropped(), uint64(0); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		lw, err := NewRateLimitedLineWriter(&errOnWrite{}, 2, 0)
+		ensureErrorNil(t, err)
+		lw.Clock = newTestClock()
+
+		n, err := lw.Write([]byte("line 1\n"))
+		ensureError(t, err, "test write error")
+		if got, want := n, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, lw.Close(), "test close error")
+	})
+}
@@ -0,0 +1,118 @@
+package gonl
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// RetryWriter is an io.WriteCloser that retries failed and short
+// writes to the underlying io.WriteCloser, waiting between attempts
+// with an exponential backoff.
+//
+// It is intended to be used as the io.WriteCloser for a
+// BatchLineWriter or PerLineWriter whose destination fails
+// transiently, such as a socket or a pipe to a daemon that restarts.
+// Each retry resumes with the first byte the previous attempt did not
+// write, so no line is duplicated or torn by a retry.
+type RetryWriter struct {
+	// Retryable, when not nil, is invoked with each error returned by
+	// the underlying io.WriteCloser, and returns true when the write
+	// ought to be retried. When nil, all errors are retried. A short
+	// write without an error is reported to Retryable as
+	// io.ErrShortWrite.
+	Retryable func(error) bool
+
+	// Clock, when not nil, is used to wait between attempts. When
+	// nil, the system clock is used.
+	Clock Clock
+
+	wc          io.WriteCloser
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+// NewRetryWriter returns a new RetryWriter that attempts each write to
+// the provided io.WriteCloser up to maxAttempts times. The delay
+// before the first retry is backoff, and the delay doubles after each
+// successive failure, up to maxBackoff.
+func NewRetryWriter(wc io.WriteCloser, maxAttempts int, backoff, maxBackoff time.Duration) (*RetryWriter, error) {
+	if maxAttempts <= 0 {
+		return nil, &ConfigError{Type: "RetryWriter", Reason: fmt.Sprintf("when maxAttempts less than or equal to 0: %d", maxAttempts)}
+	}
+	if backoff < 0 || maxBackoff < backoff {
+		return nil, &ConfigError{Type: "RetryWriter", Reason: fmt.Sprintf("when backoff less than 0 or greater than maxBackoff: %v; %v", backoff, maxBackoff)}
+	}
+	return &RetryWriter{
+		wc:          wc,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		maxBackoff:  maxBackoff,
+	}, nil
+}
+
+// Close closes the underlying io.WriteCloser. It is not retried.
+// Invoking Close more than once has no effect and returns nil.
+func (rw *RetryWriter) Close() error {
+	if rw.wc == nil {
+		return nil // already closed
+	}
+	err := rw.wc.Close()
+	rw.wc = nil
+	return err
+}
+
+// Write writes p to the underlying io.WriteCloser, retrying from the
+// first unwritten byte when an attempt returns a retryable error or
+// does not write all of p. An attempt that writes at least one byte
+// resets both the attempt count and the backoff, so a slow but
+// progressing destination is never abandoned. It returns the number
+// of bytes from p that were written, along with the final error when
+// that is less than len(p). It returns ErrClosed after the RetryWriter
+// has been closed.
+func (rw *RetryWriter) Write(p []byte) (int, error) {
+	if rw.wc == nil {
+		return 0, ErrClosed
+	}
+
+	var nw, attempts int
+	delay := rw.backoff
+
+	for {
+		n, err := rw.wc.Write(p[nw:])
+		if n < 0 || n > len(p)-nw {
+			return nw, ErrInvalidWrite
+		}
+		nw += n
+		if nw == len(p) {
+			return nw, err
+		}
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		if rw.Retryable != nil && !rw.Retryable(err) {
+			return nw, err
+		}
+
+		if n > 0 {
+			attempts = 0
+			delay = rw.backoff
+		}
+		if attempts++; attempts >= rw.maxAttempts {
+			return nw, err
+		}
+
+		rw.clock().Sleep(delay)
+		if delay *= 2; delay > rw.maxBackoff {
+			delay = rw.maxBackoff
+		}
+	}
+}
+
+func (rw *RetryWriter) clock() Clock {
+	if rw.Clock == nil {
+		return systemClock{}
+	}
+	return rw.Clock
+}
@@ -0,0 +1,139 @@
+package gonl
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// flakyWriteCloser is an io.WriteCloser that writes at most the
+// specified number of bytes for each successive Write call, returning
+// the corresponding error. Once its results are exhausted, it writes
+// everything.
+type flakyWriteCloser struct {
+	testBuffer
+	results []flakyResult
+	calls   int
+}
+
+type flakyResult struct {
+	n   int
+	err error
+}
+
+func (f *flakyWriteCloser) Write(p []byte) (int, error) {
+	f.calls++
+	if len(f.results) == 0 {
+		return f.testBuffer.Write(p)
+	}
+	r := f.results[0]
+	f.results = f.results[1:]
+	if r.n > len(p) {
+		r.n = len(p)
+	}
+	n, _ := f.testBuffer.Write(p[:r.n])
+	return n, r.err
+}
+
+func TestRetryWriter(t *testing.T) {
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+
+	t.Run("NewRetryWriter", func(t *testing.T) {
+		_, err := NewRetryWriter(new(discardWriteCloser), 0, 0, 0)
+		ensureError(t, err, "maxAttempts")
+
+		_, err = NewRetryWriter(new(discardWriteCloser), 1, time.Second, time.Millisecond)
+		ensureError(t, err, "backoff")
+	})
+
+	t.Run("resumes from short write", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{
+			{4, io.ErrShortWrite},
+			{0, errTransient},
+			{1, errTransient},
+			{0, errTransient},
+			{2, nil},
+		}}
+		clock := newTestClock()
+		rw, err := NewRetryWriter(output, 3, time.Second, 10*time.Second)
+		ensureErrorNil(t, err)
+		rw.Clock = clock
+
+		lw := NewPerLineWriter(rw)
+		ensureWrite(t, lw, "line 1\nline 2\n")
+		ensureErrorNil(t, lw.Close())
+
+		ensureStringer(t, output, "line 1\nline 2\n")
+		if got, want := output.calls, 6; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		// Backoff reset after each attempt that made progress.
+		if got, want := clock.slept, 6*time.Second; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("exponential backoff", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{
+			{0, errTransient},
+			{0, errTransient},
+			{0, errTransient},
+			{0, errTransient},
+		}}
+		clock := newTestClock()
+		rw, err := NewRetryWriter(output, 5, time.Second, 3*time.Second)
+		ensureErrorNil(t, err)
+		rw.Clock = clock
+
+		ensureWrite(t, rw, "line 1\n")
+		if got, want := clock.slept, 9*time.Second; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{
+			{2, errTransient},
+			{0, errTransient},
+			{0, errTransient},
+		}}
+		rw, err := NewRetryWriter(output, 2, time.Second, time.Second)
+		ensureErrorNil(t, err)
+		rw.Clock = newTestClock()
+
+		n, err := rw.Write([]byte("line 1\n"))
+		if got, want := err, errTransient; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := n, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := output.calls, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{
+			{0, errFatal},
+		}}
+		rw, err := NewRetryWriter(output, 5, time.Second, time.Second)
+		ensureErrorNil(t, err)
+		clock := newTestClock()
+		rw.Clock = clock
+		rw.Retryable = func(err error) bool { return err != errFatal }
+
+		n, err := rw.Write([]byte("line 1\n"))
+		if got, want := err, errFatal; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := n, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := clock.sleeps, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,420 @@
+package gonl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RotatingFileWriter is an io.WriteCloser that writes to a file,
+// rotating it when it reaches a configured size, line count, or age.
+//
+// Bytes written to a RotatingFileWriter are buffered by a
+// BatchLineWriter, which only ever emits newline terminated
+// sequences of bytes, and rotation only ever takes place on a line
+// boundary, so no line is ever split across two files. When rotation
+// fails during Write, the bytes not yet written to a file remain
+// buffered, and the error is returned, allowing a later Write or Close
+// to try again. Because Close cannot be retried, when rotation fails
+// during Close, the remaining lines are instead written to the file
+// that was to be rotated, and Close returns the rotation error.
+//
+// Before a file is rotated, it is synced to stable storage. The file
+// is then renamed using RotatedName, and a new file is created at the
+// original path. Rotated files are pruned by MaxBackups and MaxAge.
+//
+// Configuration fields ought to be set before the first Write.
+type RotatingFileWriter struct {
+	// MaxBytes, when greater than 0, causes the file to be rotated
+	// before writing a line would make it larger than MaxBytes. A
+	// line larger than MaxBytes is written to a new file by itself.
+	MaxBytes int64
+
+	// MaxLines, when greater than 0, causes the file to be rotated
+	// once it holds MaxLines lines.
+	MaxLines int64
+
+	// Interval, when greater than 0, causes the file to be rotated
+	// before writing to it once Interval has elapsed since the first
+	// write to the file. Files are only rotated when written to.
+	Interval time.Duration
+
+	// RotatedName, when not nil, returns the name a file ought to be
+	// renamed to when it is rotated at time t. In order for rotated
+	// files to be pruned, the returned name must be in the same
+	// directory as path, and IsRotatedName must be provided. When nil,
+	// a timestamp is appended to path.
+	RotatedName func(path string, t time.Time) string
+
+	// IsRotatedName, when not nil, returns true when name, the path of
+	// a file in the same directory as path, could have been returned
+	// by RotatedName. Only files it returns true for are pruned. When
+	// RotatedName is not nil and IsRotatedName is nil, rotated files
+	// are never pruned. When RotatedName is nil, only files whose names
+	// have the default timestamp format are pruned.
+	IsRotatedName func(path, name string) bool
+
+	// MaxBackups, when greater than 0, limits the number of rotated
+	// files retained, removing the oldest files.
+	MaxBackups int
+
+	// MaxAge, when greater than 0, removes rotated files whose
+	// modification time is older than MaxAge.
+	MaxAge time.Duration
+
+	// Clock, when not nil, is used to determine when files ought to
+	// be rotated and pruned by time. When nil, the system clock is
+	// used.
+	Clock Clock
+
+	lw *BatchLineWriter
+	rf *rotatingFile
+}
+
+// NewRotatingFileWriter returns a new RotatingFileWriter that appends
+// to the file at path, creating it when necessary, and buffers up to
+// flushThreshold bytes before writing completed lines to the file.
+func NewRotatingFileWriter(path string, flushThreshold int) (*RotatingFileWriter, error) {
+	w := new(RotatingFileWriter)
+	rf := &rotatingFile{w: w, path: path}
+
+	lw, err := NewBatchLineWriter(rf, flushThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if err = rf.open(); err != nil {
+		return nil, err
+	}
+
+	// Account for the size and number of lines of existing file.
+	fi, err := rf.f.Stat()
+	if err != nil {
+		_ = rf.f.Close()
+		return nil, err
+	}
+	rf.size = fi.Size()
+	if rf.size > 0 {
+		fh, err := os.Open(path)
+		if err != nil {
+			_ = rf.f.Close()
+			return nil, err
+		}
+		lines, err := NewlineCounter(fh)
+		_ = fh.Close()
+		if err != nil {
+			_ = rf.f.Close()
+			return nil, err
+		}
+		rf.lines = int64(lines)
+	}
+
+	w.lw = lw
+	w.rf = rf
+	return w, nil
+}
+
+// Close flushes all buffered data to the file, including bytes
+// without a trailing newline, then syncs and closes the file. When
+// rotation fails, the remaining lines are written to the current file
+// rather than being lost, and the rotation error is returned.
+func (w *RotatingFileWriter) Close() error {
+	w.rf.closing = true
+	err := w.lw.Close()
+	if err == nil {
+		err = w.rf.rotateErr
+	}
+	w.rf.rotateErr = nil
+	return err
+}
+
+// ReadFrom reads data from r until io.EOF or error, writing completed
+// lines to the file as the buffer fills. See BatchLineWriter.ReadFrom.
+func (w *RotatingFileWriter) ReadFrom(r io.Reader) (int64, error) { return w.lw.ReadFrom(r) }
+
+// Write buffers p, writing completed lines to the file, rotating it
+// as required, when the buffer fills. See BatchLineWriter.Write.
+func (w *RotatingFileWriter) Write(p []byte) (int, error) { return w.lw.Write(p) }
+
+func (w *RotatingFileWriter) clock() Clock {
+	if w.Clock == nil {
+		return systemClock{}
+	}
+	return w.Clock
+}
+
+// rotatingFile is the io.WriteCloser a RotatingFileWriter's
+// BatchLineWriter writes to. Other than when it is closed, it is only
+// given newline terminated sequences of bytes, so it may rotate
+// between any two lines.
+type rotatingFile struct {
+	w      *RotatingFileWriter
+	f      *os.File
+	path   string
+	opened time.Time // when first written to; zero until then
+	size   int64
+	lines  int64
+
+	// closing is true while the RotatingFileWriter is being closed,
+	// when a failed rotation cannot be retried.
+	closing bool
+
+	// rotateErr is the error from a rotation that failed during
+	// Close, after which no further rotation is attempted.
+	rotateErr error
+}
+
+func (rf *rotatingFile) Close() error {
+	if rf.f == nil {
+		return nil // previous rotation failed to open new file
+	}
+	err := rf.f.Sync()
+	if cerr := rf.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// needsRotation returns true when a line of length n must be written
+// to a new file, given the current file would otherwise hold size
+// bytes and lines lines. An empty file never needs to be rotated.
+func (rf *rotatingFile) needsRotation(size, lines int64, n int, now time.Time) bool {
+	if size == 0 {
+		return false
+	}
+	w := rf.w
+	return (w.MaxBytes > 0 && size+int64(n) > w.MaxBytes) ||
+		(w.MaxLines > 0 && lines >= w.MaxLines) ||
+		(w.Interval > 0 && now.Sub(rf.opened) >= w.Interval)
+}
+
+// open opens a new file at the original path.
+func (rf *rotatingFile) open() error {
+	rf.opened = time.Time{}
+	rf.size = 0
+	rf.lines = 0
+	return rf.reopen()
+}
+
+// isRotated returns true when name, the path of a file in the same
+// directory as the file, could have been produced by rotating it,
+// including a numeric suffix added to avoid clobbering another file.
+func (rf *rotatingFile) isRotated(name string) bool {
+	matches := rf.w.IsRotatedName
+	if matches == nil {
+		if rf.w.RotatedName != nil {
+			return false // cannot know which names it produces
+		}
+		matches = isDefaultRotatedName
+	}
+	path := filepath.Clean(rf.path)
+	if matches(path, name) {
+		return true
+	}
+	i := strings.LastIndexByte(name, '.')
+	return i != -1 && isDigits(name[i+1:]) && matches(path, name[:i])
+}
+
+// defaultRotatedLayout is the time layout of the suffix appended to
+// the path of a rotated file when RotatedName is nil.
+const defaultRotatedLayout = "20060102T150405.000000000"
+
+// isDefaultRotatedName returns true when name is path followed by a
+// period and a timestamp in the default layout.
+func isDefaultRotatedName(path, name string) bool {
+	if !strings.HasPrefix(name, path+".") {
+		return false
+	}
+	_, err := time.Parse(defaultRotatedLayout, name[len(path)+1:])
+	return err == nil
+}
+
+// isDigits returns true when s is not empty and holds only decimal
+// digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// prune removes rotated files in excess of MaxBackups, and rotated
+// files older than MaxAge. Files whose names could not have been
+// produced by rotation are never removed.
+func (rf *rotatingFile) prune(now time.Time) error {
+	w := rf.w
+	if w.MaxBackups <= 0 && w.MaxAge <= 0 {
+		return nil
+	}
+	if w.RotatedName != nil && w.IsRotatedName == nil {
+		return nil
+	}
+
+	dir := filepath.Dir(rf.path)
+
+	fh, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	infos, err := fh.Readdir(-1)
+	_ = fh.Close()
+	if err != nil {
+		return err
+	}
+
+	var rotated []os.FileInfo
+	for _, fi := range infos {
+		if fi.Mode().IsRegular() && rf.isRotated(filepath.Join(dir, fi.Name())) {
+			rotated = append(rotated, fi)
+		}
+	}
+
+	// Newest first.
+	sort.Slice(rotated, func(i, j int) bool {
+		return rotated[i].ModTime().After(rotated[j].ModTime())
+	})
+
+	for i, fi := range rotated {
+		if (w.MaxBackups > 0 && i >= w.MaxBackups) || (w.MaxAge > 0 && now.Sub(fi.ModTime()) > w.MaxAge) {
+			if err := os.Remove(filepath.Join(dir, fi.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rotate syncs and closes the current file, renames it, opens a new
+// file at the original path, and prunes old rotated files. When any
+// step fails, the next Write will reopen the file at the original
+// path, and try again.
+func (rf *rotatingFile) rotate(now time.Time) error {
+	if err := rf.f.Sync(); err != nil {
+		return err
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	if err != nil {
+		return err
+	}
+
+	var name string
+	if rf.w.RotatedName != nil {
+		name = rf.w.RotatedName(rf.path, now)
+	} else {
+		name = rf.path + "." + now.UTC().Format(defaultRotatedLayout)
+	}
+	// Never clobber a previously rotated file.
+	for i, base := 1, name; ; i++ {
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			break
+		}
+		name = base + "." + strconv.Itoa(i)
+	}
+
+	if err = os.Rename(rf.path, name); err != nil {
+		return err
+	}
+	if err = rf.open(); err != nil {
+		return err
+	}
+	rf.opened = now
+	return rf.prune(now)
+}
+
+// reopen opens the file at the original path after a failed rotation,
+// preserving the accounting of its size and number of lines.
+func (rf *rotatingFile) reopen() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	rf.f = f
+	return nil
+}
+
+// Write writes p to the file, rotating the file between lines as
+// required. Consecutive lines destined for the same file are written
+// with a single Write call. It returns the number of bytes written to
+// the file.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	if rf.f == nil {
+		if err := rf.reopen(); err != nil {
+			return 0, err
+		}
+	}
+	now := rf.w.clock().Now()
+	if rf.opened.IsZero() {
+		rf.opened = now
+	}
+
+	var nw int           // bytes of p written to files
+	var chunk int        // bytes of p[nw:] destined for current file
+	var chunkLines int64 // number of lines in chunk
+
+	for nw+chunk < len(p) {
+		n := bytes.IndexByte(p[nw+chunk:], '\n')
+		if n == -1 {
+			n = len(p) - nw - chunk // final bytes without newline
+		} else {
+			n++ // include newline
+		}
+
+		if rf.rotateErr == nil && rf.needsRotation(rf.size+int64(chunk), rf.lines+chunkLines, n, now) {
+			if chunk > 0 {
+				m, err := rf.writeChunk(p[nw:nw+chunk], chunkLines)
+				nw += m
+				if err != nil {
+					return nw, err
+				}
+				chunk, chunkLines = 0, 0
+			}
+			if err := rf.rotate(now); err != nil {
+				if !rf.closing {
+					return nw, err
+				}
+				// Close cannot be retried, so rather than losing the
+				// remaining lines, write them to the current file.
+				if rf.f == nil {
+					if rerr := rf.reopen(); rerr != nil {
+						return nw, err
+					}
+				}
+				rf.rotateErr = err
+			}
+		}
+
+		chunk += n
+		chunkLines++
+	}
+
+	if chunk > 0 {
+		m, err := rf.writeChunk(p[nw:nw+chunk], chunkLines)
+		nw += m
+		if err != nil {
+			return nw, err
+		}
+	}
+	return nw, nil
+}
+
+// writeChunk writes p, which holds lines lines, to the current file.
+func (rf *rotatingFile) writeChunk(p []byte, lines int64) (int, error) {
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	if n == len(p) {
+		rf.lines += lines
+	} else {
+		rf.lines += int64(bytes.Count(p[:n], []byte{'\n'}))
+	}
+	return n, err
+}
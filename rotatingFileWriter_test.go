@@ -0,0 +1,281 @@
+package gonl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ensureFiles ensures dir holds exactly the files with the specified
+// names and contents.
+func ensureFiles(tb testing.TB, dir string, want map[string]string) {
+	tb.Helper()
+	infos, err := ioutil.ReadDir(dir)
+	ensureErrorNil(tb, err)
+
+	var got []string
+	for _, fi := range infos {
+		got = append(got, fi.Name())
+	}
+	var names []string
+	for name := range want {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if g, w := len(got), len(names); g != w {
+		tb.Fatalf("GOT: %v; WANT: %v", got, names)
+	}
+	for i, name := range names {
+		if got[i] != name {
+			tb.Fatalf("GOT: %v; WANT: %v", got, names)
+		}
+		buf, err := ioutil.ReadFile(filepath.Join(dir, name))
+		ensureErrorNil(tb, err)
+		if g, w := string(buf), want[name]; g != w {
+			tb.Errorf("%s: GOT: %q; WANT: %q", name, g, w)
+		}
+	}
+}
+
+// sequentialNames returns a RotatedName function that names rotated
+// files with a sequence number rather than a timestamp.
+func sequentialNames() func(string, time.Time) string {
+	var i int
+	return func(path string, _ time.Time) string {
+		i++
+		return path + "." + strconv.Itoa(i)
+	}
+}
+
+// isSequentialName is the IsRotatedName function for the names
+// returned by sequentialNames.
+func isSequentialName(path, name string) bool {
+	return strings.HasPrefix(name, path+".") && isDigits(name[len(path)+1:])
+}
+
+func TestRotatingFileWriter(t *testing.T) {
+	t.Run("NewRotatingFileWriter", func(t *testing.T) {
+		_, err := NewRotatingFileWriter(filepath.Join(t.TempDir(), "log"), 0)
+		ensureError(t, err, "flushThreshold")
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		dir := t.TempDir()
+		w, err := NewRotatingFileWriter(filepath.Join(dir, "log"), 1)
+		ensureErrorNil(t, err)
+		w.MaxBytes = 14
+		w.RotatedName = sequentialNames()
+
+		// Single write with many lines is split between files.
+		ensureWrite(t, w, "line 1\nline 2\nline 3\n")
+		ensureWrite(t, w, "this line is too long\nline")
+		ensureWrite(t, w, " 5\n6")
+		ensureErrorNil(t, w.Close())
+
+		ensureFiles(t, dir, map[string]string{
+			"log":   "line 5\n6",
+			"log.1": "line 1\nline 2\n",
+			"log.2": "line 3\n",
+			"log.3": "this line is too long\n",
+		})
+	})
+
+	t.Run("max lines", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "log")
+
+		// Existing lines are counted.
+		ensureErrorNil(t, ioutil.WriteFile(path, []byte("line 0\n"), 0644))
+
+		w, err := NewRotatingFileWriter(path, 64)
+		ensureErrorNil(t, err)
+		w.MaxLines = 2
+		w.RotatedName = sequentialNames()
+
+		ensureWrite(t, w, "line 1\nline 2\nline 3\nline 4")
+		ensureErrorNil(t, w.Close())
+
+		ensureFiles(t, dir, map[string]string{
+			"log":   "line 4",
+			"log.1": "line 0\nline 1\n",
+			"log.2": "line 2\nline 3\n",
+		})
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		dir := t.TempDir()
+		clock := newTestClock()
+		w, err := NewRotatingFileWriter(filepath.Join(dir, "log"), 1)
+		ensureErrorNil(t, err)
+		w.Interval = time.Minute
+		w.Clock = clock
+
+		ensureWrite(t, w, "line 1\n")
+		clock.Advance(30 * time.Second)
+		ensureWrite(t, w, "line 2\n")
+		clock.Advance(30 * time.Second)
+		ensureWrite(t, w, "line 3\nline 4\n")
+		ensureErrorNil(t, w.Close())
+
+		ensureFiles(t, dir, map[string]string{
+			"log":                           "line 3\nline 4\n",
+			"log.20200101T000100.000000000": "line 1\nline 2\n",
+		})
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		t.Run("max backups", func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := NewRotatingFileWriter(filepath.Join(dir, "log"), 1)
+			ensureErrorNil(t, err)
+			w.MaxLines = 1
+			w.MaxBackups = 2
+			w.RotatedName = sequentialNames()
+			w.IsRotatedName = isSequentialName
+
+			for i := 1; i <= 5; i++ {
+				ensureWrite(t, w, "line "+strconv.Itoa(i)+"\n")
+				// Ensure modification times are distinct.
+				mtime := time.Now().Add(time.Duration(i-10) * time.Second)
+				ensureErrorNil(t, os.Chtimes(filepath.Join(dir, "log"), mtime, mtime))
+			}
+			ensureErrorNil(t, w.Close())
+
+			ensureFiles(t, dir, map[string]string{
+				"log":   "line 5\n",
+				"log.3": "line 3\n",
+				"log.4": "line 4\n",
+			})
+		})
+
+		t.Run("max age", func(t *testing.T) {
+			dir := t.TempDir()
+			mtime := time.Now().Add(-2 * time.Hour)
+			for _, name := range []string{"log.0", "log.conf"} {
+				old := filepath.Join(dir, name)
+				ensureErrorNil(t, ioutil.WriteFile(old, []byte("old\n"), 0644))
+				ensureErrorNil(t, os.Chtimes(old, mtime, mtime))
+			}
+
+			w, err := NewRotatingFileWriter(filepath.Join(dir, "log"), 1)
+			ensureErrorNil(t, err)
+			w.MaxLines = 1
+			w.MaxAge = time.Hour
+			w.RotatedName = sequentialNames()
+			w.IsRotatedName = isSequentialName
+
+			ensureWrite(t, w, "line 1\nline 2\n")
+			ensureErrorNil(t, w.Close())
+
+			ensureFiles(t, dir, map[string]string{
+				"log":      "line 2\n",
+				"log.1":    "line 1\n",
+				"log.conf": "old\n",
+			})
+		})
+
+		t.Run("default names", func(t *testing.T) {
+			dir := t.TempDir()
+			mtime := time.Now().Add(-2 * time.Hour)
+			for _, name := range []string{"app.20200101T000000.000000000", "app.20200101T000000.000000000.1", "app.conf", "app.1"} {
+				old := filepath.Join(dir, name)
+				ensureErrorNil(t, ioutil.WriteFile(old, []byte("old\n"), 0644))
+				ensureErrorNil(t, os.Chtimes(old, mtime, mtime))
+			}
+
+			w, err := NewRotatingFileWriter(filepath.Join(dir, "app"), 1)
+			ensureErrorNil(t, err)
+			w.MaxLines = 1
+			w.MaxAge = time.Hour
+
+			ensureWrite(t, w, "line 1\nline 2\n")
+			ensureErrorNil(t, w.Close())
+
+			matches, err := filepath.Glob(filepath.Join(dir, "app.2*"))
+			ensureErrorNil(t, err)
+			if got, want := len(matches), 1; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", matches, want)
+			}
+			ensureFiles(t, dir, map[string]string{
+				"app":                     "line 2\n",
+				"app.1":                   "old\n",
+				"app.conf":                "old\n",
+				filepath.Base(matches[0]): "line 1\n",
+			})
+		})
+
+		t.Run("custom names without IsRotatedName", func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := NewRotatingFileWriter(filepath.Join(dir, "log"), 1)
+			ensureErrorNil(t, err)
+			w.MaxLines = 1
+			w.MaxBackups = 1
+			w.RotatedName = sequentialNames()
+
+			ensureWrite(t, w, "line 1\nline 2\nline 3\n")
+			ensureErrorNil(t, w.Close())
+
+			ensureFiles(t, dir, map[string]string{
+				"log":   "line 3\n",
+				"log.1": "line 1\n",
+				"log.2": "line 2\n",
+			})
+		})
+	})
+
+	t.Run("rotation failure retains lines", func(t *testing.T) {
+		dir := t.TempDir()
+		w, err := NewRotatingFileWriter(filepath.Join(dir, "log"), 1)
+		ensureErrorNil(t, err)
+		w.MaxLines = 1
+		w.RotatedName = func(path string, _ time.Time) string {
+			return filepath.Join(path+".missing", "log.1")
+		}
+
+		ensureWrite(t, w, "line 1\n")
+
+		n, err := w.Write([]byte("line 2\n"))
+		if err == nil {
+			t.Fatalf("GOT: %v; WANT: %v", err, "rename error")
+		}
+		if got, want := n, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// Once rotation succeeds, the line is written to new file.
+		w.RotatedName = sequentialNames()
+		ensureWrite(t, w, "line 2\n")
+		ensureErrorNil(t, w.Close())
+
+		ensureFiles(t, dir, map[string]string{
+			"log":   "line 2\n",
+			"log.1": "line 1\n",
+		})
+	})
+
+	t.Run("rotation failure during Close retains lines", func(t *testing.T) {
+		dir := t.TempDir()
+		w, err := NewRotatingFileWriter(filepath.Join(dir, "log"), 64)
+		ensureErrorNil(t, err)
+		w.MaxLines = 1
+		w.RotatedName = func(path string, _ time.Time) string {
+			return filepath.Join(path+".missing", "log.1")
+		}
+
+		ensureWrite(t, w, "line 1\nline 2\nline 3")
+		if err := w.Close(); !os.IsNotExist(err) {
+			t.Fatalf("GOT: %v; WANT: %v", err, "rename error")
+		}
+
+		ensureFiles(t, dir, map[string]string{
+			"log": "line 1\nline 2\nline 3",
+		})
+		ensureErrorNil(t, w.Close())
+	})
+}
@@ -0,0 +1,193 @@
+package gonl
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"time"
+)
+
+const (
+	sampleEveryNth = iota
+	sampleRandom
+	sampleHash
+)
+
+// SamplingStats is a snapshot of how many lines a SamplingLineWriter
+// has kept and dropped.
+type SamplingStats struct {
+	Kept    uint64
+	Dropped uint64
+}
+
+// SamplingLineWriter is an io.WriteCloser that only forwards a subset
+// of the completed lines written to it to the underlying
+// io.WriteCloser. It is useful for debugging high volume streams,
+// where it is not practical to retain every line.
+//
+// Each kept line is sent to the underlying io.WriteCloser with a
+// single Write call, including its terminating newline.
+//
+// It is important for caller to Close the SamplingLineWriter so that
+// any final line not terminated with a newline is considered for
+// sampling.
+type SamplingLineWriter struct {
+	lb lineBuffer
+	wc io.WriteCloser
+
+	// SummaryInterval, when greater than 0, causes a summary line
+	// reporting the number of kept and dropped lines to be written to
+	// the underlying io.WriteCloser once every SummaryInterval lines
+	// have been sampled, prior to sampling the following line, and
+	// when the SamplingLineWriter is closed.
+	SummaryInterval int
+
+	rand     *rand.Rand
+	fraction float64
+	mode     int
+	n        uint64 // sample every nth line
+	seen     uint64 // number of lines since last summary
+	stats    SamplingStats
+	midLine  bool // final byte written was not a newline
+}
+
+// NewEveryNthSamplingLineWriter returns a SamplingLineWriter that
+// keeps the first line, and every nth line after that.
+func NewEveryNthSamplingLineWriter(wc io.WriteCloser, n int) (*SamplingLineWriter, error) {
+	if n <= 0 {
+		return nil, &ConfigError{Type: "SamplingLineWriter", Reason: fmt.Sprintf("when n less than or equal to 0: %d", n)}
+	}
+	return &SamplingLineWriter{wc: wc, mode: sampleEveryNth, n: uint64(n)}, nil
+}
+
+// NewRandomSamplingLineWriter returns a SamplingLineWriter that keeps
+// each line with a probability of fraction, which must be between 0
+// and 1 inclusive. When source is nil, a source seeded with the
+// current time is used; provide a seeded source in order to make the
+// selection of lines repeatable.
+func NewRandomSamplingLineWriter(wc io.WriteCloser, fraction float64, source rand.Source) (*SamplingLineWriter, error) {
+	if fraction < 0 || fraction > 1 {
+		return nil, &ConfigError{Type: "SamplingLineWriter", Reason: fmt.Sprintf("when fraction not between 0 and 1: %v", fraction)}
+	}
+	if source == nil {
+		source = rand.NewSource(time.Now().UnixNano())
+	}
+	return &SamplingLineWriter{wc: wc, mode: sampleRandom, fraction: fraction, rand: rand.New(source)}, nil
+}
+
+// NewHashSamplingLineWriter returns a SamplingLineWriter that keeps
+// approximately fraction of the lines, which must be between 0 and 1
+// inclusive, based on a hash of each line's contents. Lines with
+// identical contents are therefore either always kept or always
+// dropped, even across different program invocations. The
+// terminating newline is not included in the hash, so a final line
+// emitted during Close is sampled the same as if it had been
+// terminated.
+func NewHashSamplingLineWriter(wc io.WriteCloser, fraction float64) (*SamplingLineWriter, error) {
+	if fraction < 0 || fraction > 1 {
+		return nil, &ConfigError{Type: "SamplingLineWriter", Reason: fmt.Sprintf("when fraction not between 0 and 1: %v", fraction)}
+	}
+	return &SamplingLineWriter{wc: wc, mode: sampleHash, fraction: fraction}, nil
+}
+
+// Close considers any final line not terminated by a newline for
+// sampling, writes the final summary line when SummaryInterval is
+// greater than 0, then closes the underlying io.WriteCloser. Invoking
+// Close more than once has no effect and returns nil.
+func (lw *SamplingLineWriter) Close() error {
+	if lw.wc == nil {
+		return nil // already closed
+	}
+	err := lw.lb.flush(lw.sample)
+	if err == nil && lw.SummaryInterval > 0 && lw.seen > 0 {
+		err = lw.summarize()
+	}
+	if err != nil {
+		_ = lw.wc.Close()
+		lw.wc = nil
+		return err
+	}
+	err = lw.wc.Close()
+	lw.wc = nil
+	return err
+}
+
+// keep returns true when the specified line ought to be kept.
+func (lw *SamplingLineWriter) keep(line []byte) bool {
+	switch lw.mode {
+	case sampleRandom:
+		return lw.rand.Float64() < lw.fraction
+	case sampleHash:
+		if l := len(line); l > 0 && line[l-1] == '\n' {
+			line = line[:l-1]
+		}
+		h := fnv.New64a()
+		_, _ = h.Write(line) // never returns an error
+		// FNV-1a poorly mixes its final input bytes into the high
+		// bits, so finish with the SplitMix64 finalizer, then use the
+		// top 53 bits to create a uniform float in [0, 1).
+		x := h.Sum64()
+		x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+		x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+		x ^= x >> 31
+		return float64(x>>11)/(1<<53) < lw.fraction
+	default:
+		return (lw.stats.Kept+lw.stats.Dropped)%lw.n == 0
+	}
+}
+
+// sample either writes line to the underlying io.WriteCloser or
+// drops it, updating the counters, after writing the summary line
+// when one is due.
+func (lw *SamplingLineWriter) sample(line []byte) error {
+	if lw.SummaryInterval > 0 && lw.seen >= uint64(lw.SummaryInterval) {
+		// Write the summary before sampling this line, so that when
+		// writing the summary fails, this line may be retried without
+		// having been counted.
+		if err := lw.summarize(); err != nil {
+			return err
+		}
+	}
+	if lw.keep(line) {
+		if _, err := writeAll(lw.wc, line); err != nil {
+			return err
+		}
+		lw.midLine = line[len(line)-1] != '\n'
+		lw.stats.Kept++
+	} else {
+		lw.stats.Dropped++
+	}
+	lw.seen++
+	return nil
+}
+
+// Stats returns the number of lines kept and dropped so far.
+func (lw *SamplingLineWriter) Stats() SamplingStats { return lw.stats }
+
+// summarize writes a summary line to the underlying io.WriteCloser,
+// preceded by a newline when the final line written was not newline
+// terminated, so the summary remains on its own line.
+func (lw *SamplingLineWriter) summarize() error {
+	var prefix string
+	if lw.midLine {
+		prefix = "\n"
+	}
+	_, err := writeAll(lw.wc, []byte(fmt.Sprintf("%sgonl: sampled %d lines: kept %d; dropped %d\n", prefix, lw.stats.Kept+lw.stats.Dropped, lw.stats.Kept, lw.stats.Dropped)))
+	if err == nil {
+		lw.seen = 0
+		lw.midLine = false
+	}
+	return err
+}
+
+// Write buffers p, and for each completed line, either writes it to
+// the underlying io.WriteCloser or drops it, according to the
+// sampling mode. It returns ErrClosed after the SamplingLineWriter has
+// been closed.
+func (lw *SamplingLineWriter) Write(p []byte) (int, error) {
+	if lw.wc == nil {
+		return 0, ErrClosed
+	}
+	return lw.lb.write(p, lw.sample)
+}
@@ -0,0 +1,113 @@
+package gonl
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSamplingLineWriter(t *testing.T) {
+	const input = "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7"
+
+	t.Run("constructors", func(t *testing.T) {
+		_, err := NewEveryNthSamplingLineWriter(new(discardWriteCloser), 0)
+		ensureError(t, err, "n less than or equal to 0")
+
+		_, err = NewRandomSamplingLineWriter(new(discardWriteCloser), 1.5, nil)
+		ensureError(t, err, "fraction")
+
+		_, err = NewHashSamplingLineWriter(new(discardWriteCloser), -0.5)
+		ensureError(t, err, "fraction")
+	})
+
+	t.Run("every nth", func(t *testing.T) {
+		output := new(testBuffer)
+		lw, err := NewEveryNthSamplingLineWriter(output, 3)
+		ensureErrorNil(t, err)
+
+		// Split input across several writes to ensure lines are
+		// reassembled before being sampled.
+		for _, p := range []string{"line 1\nli", "ne 2\nline 3\nline 4", "\nline 5\nline 6\nline 7"} {
+			ensureWrite(t, lw, p)
+		}
+		ensureErrorNil(t, lw.Close())
+
+		ensureStringer(t, output, "line 1\nline 4\nline 7")
+		if got, want := lw.Stats(), (SamplingStats{Kept: 3, Dropped: 4}); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("random", func(t *testing.T) {
+		sample := func() string {
+			output := new(testBuffer)
+			lw, err := NewRandomSamplingLineWriter(output, 0.5, rand.NewSource(42))
+			ensureErrorNil(t, err)
+			ensureWrite(t, lw, strings.Repeat(input+"\n", 10))
+			ensureErrorNil(t, lw.Close())
+
+			stats := lw.Stats()
+			if got, want := stats.Kept+stats.Dropped, uint64(70); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if stats.Kept == 0 || stats.Dropped == 0 {
+				t.Errorf("GOT: %v; WANT: some lines kept and some dropped", stats)
+			}
+			return output.String()
+		}
+
+		if first, second := sample(), sample(); first != second {
+			t.Errorf("GOT: %q; WANT: %q", second, first)
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		sample := func(p string) string {
+			output := new(testBuffer)
+			lw, err := NewHashSamplingLineWriter(output, 0.5)
+			ensureErrorNil(t, err)
+			ensureWrite(t, lw, p)
+			ensureErrorNil(t, lw.Close())
+			return output.String()
+		}
+
+		terminated := sample(input + "\n")
+		if terminated == "" || terminated == input+"\n" {
+			t.Fatalf("GOT: %q; WANT: some lines kept and some dropped", terminated)
+		}
+
+		// Identical lines are always sampled identically, including
+		// an unterminated final line.
+		unterminated := sample(input)
+		if got, want := strings.TrimSuffix(unterminated, "\n"), strings.TrimSuffix(terminated, "\n"); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		output := new(testBuffer)
+		lw, err := NewEveryNthSamplingLineWriter(output, 2)
+		ensureErrorNil(t, err)
+		lw.SummaryInterval = 4
+
+		ensureWrite(t, lw, input)
+		ensureErrorNil(t, lw.Close())
+
+		ensureStringer(t, output, "line 1\nline 3\ngonl: sampled 4 lines: kept 2; dropped 2\nline 5\nline 7\ngonl: sampled 7 lines: kept 4; dropped 3\n")
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		output := new(testBuffer)
+		lw, err := NewEveryNthSamplingLineWriter(NopCloseWriter(ShortWriter(output, 4)), 1)
+		ensureErrorNil(t, err)
+
+		n, err := lw.Write([]byte("line 1\n"))
+		ensureError(t, err, "short write")
+		if got, want := n, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := lw.Stats(), (SamplingStats{}); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package gonl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// squeezer collapses runs of consecutive empty lines of a stream
+// provided in chunks. It holds only a count of the empty lines it has
+// not yet emitted, never their bytes.
+type squeezer struct {
+	max           int
+	stripLeading  bool // copied from the parent before each use
+	stripTrailing bool // copied from the parent before each use
+	midLine       bool // next byte continues a non-empty line
+	seenContent   bool // a non-empty line has been emitted
+	blanks        int  // empty lines in the current run
+	emittedBlanks int  // empty lines of the current run emitted
+}
+
+func newSqueezer(typ string, max int) (squeezer, error) {
+	if max < 0 {
+		return squeezer{}, &ConfigError{Type: typ, Reason: fmt.Sprintf("when max less than 0: %d", max)}
+	}
+	return squeezer{max: max}, nil
+}
+
+// leading returns true while empty lines are leading blank lines that
+// are to be stripped.
+func (s *squeezer) leading() bool { return !s.seenContent && s.stripLeading }
+
+// squeeze appends the squeezed bytes of src to dst.
+func (s *squeezer) squeeze(dst, src []byte) []byte {
+	for len(src) > 0 {
+		if s.midLine {
+			index := bytes.IndexByte(src, '\n')
+			if index == -1 {
+				return append(dst, src...)
+			}
+			dst = append(dst, src[:index+1]...)
+			src = src[index+1:]
+			s.midLine = false
+			continue
+		}
+
+		if src[0] == '\n' {
+			// Empty line, emitted right away only when it cannot be
+			// stripped later.
+			s.blanks++
+			if s.emittedBlanks < s.max && !s.leading() && !s.stripTrailing {
+				dst = append(dst, '\n')
+				s.emittedBlanks++
+			}
+			src = src[1:]
+			continue
+		}
+
+		// First byte of a non-empty line.
+		dst = s.appendBlanks(dst)
+		s.blanks, s.emittedBlanks = 0, 0
+		s.seenContent = true
+		s.midLine = true
+	}
+	return dst
+}
+
+// appendBlanks appends the empty lines of the current run that have
+// not been emitted, up to max, unless they are leading blank lines
+// that are to be stripped.
+func (s *squeezer) appendBlanks(dst []byte) []byte {
+	if s.leading() {
+		return dst
+	}
+	n := s.blanks
+	if n > s.max {
+		n = s.max
+	}
+	for ; s.emittedBlanks < n; s.emittedBlanks++ {
+		dst = append(dst, '\n')
+	}
+	return dst
+}
+
+// flush appends the empty lines held at the end of the stream to dst,
+// unless trailing blank lines are to be stripped.
+func (s *squeezer) flush(dst []byte) []byte {
+	if !s.stripTrailing {
+		dst = s.appendBlanks(dst)
+	}
+	s.blanks, s.emittedBlanks = 0, 0
+	return dst
+}
+
+// SqueezeReader is an io.Reader that collapses each run of consecutive
+// empty lines read from the source io.Reader to at most the maximum
+// number of empty lines given to NewSqueezeReader, like `cat -s` does
+// when that maximum is 1. Runs that span multiple
+// reads are collapsed the same as those that do not.
+type SqueezeReader struct {
+	// StripLeading, when true, removes all empty lines before the
+	// first non-empty line of the stream. It must be set before the
+	// first Read.
+	StripLeading bool
+
+	// StripTrailing, when true, removes all empty lines after the
+	// final non-empty line of the stream. Because empty lines might
+	// be trailing until a following non-empty line is read, they are
+	// held until then. It must be set before the first Read.
+	StripTrailing bool
+
+	tr transformReader
+	sq squeezer
+}
+
+// NewSqueezeReader returns a new SqueezeReader that reads from r,
+// collapsing each run of consecutive empty lines to at most max empty
+// lines.
+func NewSqueezeReader(r io.Reader, max int) (*SqueezeReader, error) {
+	sr := new(SqueezeReader)
+	sq, err := newSqueezer("SqueezeReader", max)
+	if err != nil {
+		return nil, err
+	}
+	sr.sq = sq
+	sr.tr = transformReader{r: r, transform: sr.sq.squeeze, flush: sr.sq.flush}
+	return sr, nil
+}
+
+// Read reads up to len(p) squeezed bytes into p. It returns the
+// number of bytes read (0 <= n <= len(p)) and any error encountered.
+// The error from the source io.Reader is returned after all bytes
+// read prior to it have been returned.
+func (r *SqueezeReader) Read(p []byte) (int, error) {
+	r.sq.stripLeading, r.sq.stripTrailing = r.StripLeading, r.StripTrailing
+	return r.tr.Read(p)
+}
+
+// SqueezeWriter is an io.WriteCloser that collapses each run of
+// consecutive empty lines written to it to at most the maximum number
+// of empty lines given to NewSqueezeWriter, like `cat -s` does when
+// that maximum is 1, then writes the remaining bytes to the underlying
+// io.WriteCloser. Runs that span multiple writes are
+// collapsed the same as those that do not.
+//
+// It is important for caller to Close the SqueezeWriter to write empty
+// lines held at the end of the stream.
+type SqueezeWriter struct {
+	// StripLeading, when true, removes all empty lines before the
+	// first non-empty line of the stream. It must be set before the
+	// first Write.
+	StripLeading bool
+
+	// StripTrailing, when true, removes all empty lines after the
+	// final non-empty line of the stream. Because empty lines might
+	// be trailing until a following non-empty line is written, they
+	// are held until then. It must be set before the first Write.
+	StripTrailing bool
+
+	wc  io.WriteCloser
+	sq  squeezer
+	out []byte // squeezed bytes not yet written
+}
+
+// NewSqueezeWriter returns a new SqueezeWriter that writes to wc,
+// collapsing each run of consecutive empty lines to at most max empty
+// lines.
+func NewSqueezeWriter(wc io.WriteCloser, max int) (*SqueezeWriter, error) {
+	sw := &SqueezeWriter{wc: wc}
+	sq, err := newSqueezer("SqueezeWriter", max)
+	if err != nil {
+		return nil, err
+	}
+	sw.sq = sq
+	return sw, nil
+}
+
+// Close writes the empty lines held at the end of the stream, unless
+// StripTrailing is true, along with any bytes that remain buffered
+// because of a previous write error, then closes the underlying
+// io.WriteCloser. Invoking Close more than once has no effect and
+// returns nil.
+func (w *SqueezeWriter) Close() error {
+	if w.wc == nil {
+		return nil // already closed
+	}
+	var err error
+	w.sq.stripLeading, w.sq.stripTrailing = w.StripLeading, w.StripTrailing
+	if w.out = w.sq.flush(w.out); len(w.out) > 0 {
+		_, err = writeAll(w.wc, w.out)
+	}
+	if cerr := w.wc.Close(); err == nil {
+		err = cerr
+	}
+	w.wc = nil
+	w.out = nil
+	return err
+}
+
+// Write squeezes p, and writes the remaining bytes to the underlying
+// io.WriteCloser. Because the bytes written no longer correspond to
+// the bytes of p, when writing fails, it returns len(p) along with the
+// error, and the bytes not written remain buffered, to be written by
+// the next Write or by Close. It returns ErrClosed after the
+// SqueezeWriter has been closed.
+func (w *SqueezeWriter) Write(p []byte) (int, error) {
+	if w.wc == nil {
+		return 0, ErrClosed
+	}
+	w.sq.stripLeading, w.sq.stripTrailing = w.StripLeading, w.StripTrailing
+	if w.out = w.sq.squeeze(w.out, p); len(w.out) == 0 {
+		return len(p), nil
+	}
+	nw, err := writeAll(w.wc, w.out)
+	w.out = w.out[:copy(w.out, w.out[nw:])]
+	return len(p), err
+}
@@ -0,0 +1,80 @@
+package gonl
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSqueeze(t *testing.T) {
+	cases := []struct {
+		name          string
+		max           int
+		stripLeading  bool
+		stripTrailing bool
+		input         string
+		want          string
+	}{
+		{"empty", 1, false, false, "", ""},
+		{"no blank lines", 1, false, false, "a\nb\nc", "a\nb\nc"},
+		{"max 1", 1, false, false, "a\n\n\n\nb\n\nc\n", "a\n\nb\n\nc\n"},
+		{"max 0", 0, false, false, "a\n\n\nb\n", "a\nb\n"},
+		{"max 2", 2, false, false, "a\n\n\n\n\nb\n", "a\n\n\nb\n"},
+		{"leading kept", 1, false, false, "\n\n\na\n", "\na\n"},
+		{"leading stripped", 1, true, false, "\n\n\na\n\n\nb\n", "a\n\nb\n"},
+		{"trailing kept", 1, false, false, "a\n\n\n\n", "a\n\n"},
+		{"trailing stripped", 1, false, true, "a\n\n\nb\n\n\n", "a\n\nb\n"},
+		{"only blank lines stripped", 1, true, true, "\n\n\n", ""},
+		{"blank lines with spaces are not empty", 0, false, false, "a\n \n\nb\n", "a\n \nb\n"},
+	}
+
+	for _, c := range cases {
+		t.Run("SqueezeWriter/"+c.name, func(t *testing.T) {
+			output := new(testBuffer)
+			w, err := NewSqueezeWriter(output, c.max)
+			ensureErrorNil(t, err)
+			w.StripLeading, w.StripTrailing = c.stripLeading, c.stripTrailing
+			ensureWrite(t, w, c.input)
+			ensureErrorNil(t, w.Close())
+			ensureStringer(t, output, c.want)
+		})
+		t.Run("SqueezeWriter/"+c.name+" one byte writes", func(t *testing.T) {
+			output := new(testBuffer)
+			w, err := NewSqueezeWriter(output, c.max)
+			ensureErrorNil(t, err)
+			w.StripLeading, w.StripTrailing = c.stripLeading, c.stripTrailing
+			for i := 0; i < len(c.input); i++ {
+				ensureWrite(t, w, c.input[i:i+1])
+			}
+			ensureErrorNil(t, w.Close())
+			ensureStringer(t, output, c.want)
+		})
+		t.Run("SqueezeReader/"+c.name, func(t *testing.T) {
+			r, err := NewSqueezeReader(iotest.OneByteReader(strings.NewReader(c.input)), c.max)
+			ensureErrorNil(t, err)
+			r.StripLeading, r.StripTrailing = c.stripLeading, c.stripTrailing
+			buf, err := ioutil.ReadAll(r)
+			ensureErrorNil(t, err)
+			if got, want := string(buf), c.want; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		})
+	}
+
+	t.Run("max less than 0", func(t *testing.T) {
+		_, err := NewSqueezeWriter(new(testBuffer), -1)
+		ensureError(t, err, "cannot create SqueezeWriter when max less than 0")
+		_, err = NewSqueezeReader(strings.NewReader(""), -1)
+		ensureError(t, err, "cannot create SqueezeReader when max less than 0")
+	})
+
+	t.Run("blank lines written before next line", func(t *testing.T) {
+		output := new(testBuffer)
+		w, err := NewSqueezeWriter(output, 1)
+		ensureErrorNil(t, err)
+		ensureWrite(t, w, "a\n\n\n")
+		ensureStringer(t, output, "a\n\n")
+		ensureErrorNil(t, w.Close())
+	})
+}
@@ -0,0 +1,118 @@
+package gonl
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+)
+
+// LineWriterStats is a snapshot of the activity of a BatchLineWriter
+// or PerLineWriter since it was created.
+type LineWriterStats struct {
+	// Lines is the number of newline terminated lines written to the
+	// underlying io.WriteCloser.
+	Lines int64
+
+	// BytesIn is the number of bytes accepted by Write and ReadFrom.
+	BytesIn int64
+
+	// BytesOut is the number of bytes written to the underlying
+	// io.WriteCloser.
+	BytesOut int64
+
+	// Writes is the number of times Write was invoked on the
+	// underlying io.WriteCloser.
+	Writes int64
+
+	// Flushes is the number of times buffered lines were flushed
+	// before Close: by reaching the flush threshold or a cancelled
+	// ReadFromContext for a BatchLineWriter, and by each newline for
+	// a PerLineWriter.
+	Flushes int64
+
+	// CloseFlushes is the number of times Close flushed buffered
+	// bytes, which is either 0 or 1.
+	CloseFlushes int64
+
+	// PeakBuffer is the largest number of bytes held in the buffer.
+	PeakBuffer int64
+
+	// Errors is the number of errors returned by the underlying
+	// io.WriteCloser.
+	Errors int64
+
+	// Overflows is the number of times a partial line exceeded the
+	// maximum buffer size of a BatchLineWriter created by
+	// NewBoundedBatchLineWriter.
+	Overflows int64
+}
+
+// counters holds the statistics of a line writer. The line writer
+// updates them from the goroutine that uses it, using atomic
+// operations so a snapshot may be loaded from any goroutine.
+type counters struct {
+	bytesIn      int64
+	bytesOut     int64 // also the offset for WriteError
+	lines        int64 // newlines delivered; also for WriteError
+	writes       int64
+	flushes      int64
+	closeFlushes int64
+	peakBuffer   int64
+	errors       int64
+	overflows    int64
+
+	// midLine is true when the final byte delivered was not a
+	// newline. Unlike the counters, it is only accessed by the line
+	// writer, so it is not part of a snapshot.
+	midLine bool
+}
+
+// buffered records that the buffer holds n bytes.
+func (c *counters) buffered(n int) {
+	// Only the goroutine using the line writer stores peakBuffer, so
+	// it need not compare and swap.
+	if n := int64(n); n > atomic.LoadInt64(&c.peakBuffer) {
+		atomic.StoreInt64(&c.peakBuffer, n)
+	}
+}
+
+// failed records an error returned by the underlying io.WriteCloser.
+func (c *counters) failed(err error) error {
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+	}
+	return err
+}
+
+func (c *counters) snapshot() LineWriterStats {
+	return LineWriterStats{
+		Lines:        atomic.LoadInt64(&c.lines),
+		BytesIn:      atomic.LoadInt64(&c.bytesIn),
+		BytesOut:     atomic.LoadInt64(&c.bytesOut),
+		Writes:       atomic.LoadInt64(&c.writes),
+		Flushes:      atomic.LoadInt64(&c.flushes),
+		CloseFlushes: atomic.LoadInt64(&c.closeFlushes),
+		PeakBuffer:   atomic.LoadInt64(&c.peakBuffer),
+		Errors:       atomic.LoadInt64(&c.errors),
+		Overflows:    atomic.LoadInt64(&c.overflows),
+	}
+}
+
+// writeAll is like the writeAll function, but also records the bytes,
+// newlines, and Write calls delivered, and wraps any error in a
+// WriteError that reports the position of the first byte not
+// delivered.
+func (c *counters) writeAll(w io.Writer, p []byte) (int, error) {
+	nw, calls, err := writeAllCalls(w, p)
+	offset := atomic.AddInt64(&c.bytesOut, int64(nw))
+	lines := atomic.AddInt64(&c.lines, int64(bytes.Count(p[:nw], []byte{'\n'})))
+	atomic.AddInt64(&c.writes, int64(calls))
+	if nw > 0 {
+		c.midLine = p[nw-1] != '\n'
+	}
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+		err = &WriteError{Offset: offset, Line: lines + 1, Err: err}
+	}
+	return nw, err
+}
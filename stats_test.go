@@ -0,0 +1,86 @@
+package gonl
+
+import (
+	"sync"
+	"testing"
+)
+
+func ensureStats(tb testing.TB, got, want LineWriterStats) {
+	tb.Helper()
+	if got != want {
+		tb.Errorf("GOT: %+v; WANT: %+v", got, want)
+	}
+}
+
+func TestLineWriterStats(t *testing.T) {
+	t.Run("BatchLineWriter", func(t *testing.T) {
+		output := new(testBuffer)
+		lw, err := NewBatchLineWriter(output, 8)
+		ensureErrorNil(t, err)
+
+		ensureWrite(t, lw, "line 1\n")
+		ensureStats(t, lw.Stats(), LineWriterStats{BytesIn: 7, PeakBuffer: 7})
+
+		ensureWrite(t, lw, "line 2\nli")
+		ensureStats(t, lw.Stats(), LineWriterStats{Lines: 2, BytesIn: 16, BytesOut: 14, Writes: 1, Flushes: 1, PeakBuffer: 16})
+
+		ensureErrorNil(t, lw.Close())
+		ensureStats(t, lw.Stats(), LineWriterStats{Lines: 2, BytesIn: 16, BytesOut: 16, Writes: 2, Flushes: 1, CloseFlushes: 1, PeakBuffer: 16})
+		ensureStringer(t, output, "line 1\nline 2\nli")
+	})
+
+	t.Run("BatchLineWriter errors", func(t *testing.T) {
+		lw, err := NewBatchLineWriter(&errOnWrite{}, 1)
+		ensureErrorNil(t, err)
+
+		n, err := lw.Write([]byte("line 1\n"))
+		ensureError(t, err, "test write error")
+		if got, want := n, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStats(t, lw.Stats(), LineWriterStats{Writes: 1, Flushes: 1, PeakBuffer: 7, Errors: 1})
+	})
+
+	t.Run("PerLineWriter", func(t *testing.T) {
+		output := new(testBuffer)
+		lw := NewPerLineWriter(output)
+
+		ensureWrite(t, lw, "line 1\nline 2\nli")
+		ensureStats(t, lw.Stats(), LineWriterStats{Lines: 2, BytesIn: 16, BytesOut: 14, Writes: 2, Flushes: 2, PeakBuffer: 16})
+
+		ensureErrorNil(t, lw.Close())
+		ensureStats(t, lw.Stats(), LineWriterStats{Lines: 2, BytesIn: 16, BytesOut: 16, Writes: 3, Flushes: 2, CloseFlushes: 1, PeakBuffer: 16})
+		ensureStringer(t, output, "line 1\nline 2\nli")
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		lw, err := NewBatchLineWriter(new(discardWriteCloser), 64)
+		ensureErrorNil(t, err)
+
+		var wg sync.WaitGroup
+		done := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					_ = lw.Stats()
+				}
+			}
+		}()
+
+		for i := 0; i < 1000; i++ {
+			ensureWrite(t, lw, "line\n")
+		}
+		close(done)
+		wg.Wait()
+
+		ensureErrorNil(t, lw.Close())
+		if got, want := lw.Stats().Lines, int64(1000); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
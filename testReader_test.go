@@ -33,6 +33,22 @@ type tuple struct {
 	e error
 }
 
+// cancelingReader is an io.Reader that invokes cancel after it has
+// been read from the specified number of times.
+type cancelingReader struct {
+	io.Reader
+	cancel func()
+	reads  int
+}
+
+func (cr *cancelingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	if cr.reads--; cr.reads == 0 {
+		cr.cancel()
+	}
+	return n, err
+}
+
 // TestReader ensures that the testReader is working properly.
 func TestReader(t *testing.T) {
 	buf := make([]byte, 64)
@@ -0,0 +1,62 @@
+package gonl
+
+import (
+	"errors"
+	"io"
+)
+
+// transformReader reads chunks from r, and returns the bytes produced
+// by transforming each chunk. It is used by the readers that convert
+// the bytes of a stream while keeping state across chunk boundaries.
+type transformReader struct {
+	r io.Reader
+
+	// transform appends the transformation of src to dst.
+	transform func(dst, src []byte) []byte
+
+	// flush appends any bytes the transformation holds at the end of
+	// the stream to dst. It is only invoked once r returns io.EOF, or
+	// an error that wraps it.
+	flush func(dst []byte) []byte
+
+	in  []byte // bytes read from r
+	buf []byte // backing array for out
+	out []byte // transformed bytes not yet returned
+	err error  // error from r, returned once out is empty
+}
+
+// Read reads up to len(p) transformed bytes into p. The error from r
+// is returned after all bytes transformed prior to it have been
+// returned. Only io.EOF, or an error that wraps it, ends the stream.
+// Any other error is returned once, while the transformation keeps
+// its state, so a later Read may continue reading from r.
+func (tr *transformReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for len(tr.out) == 0 {
+		if tr.err != nil {
+			err := tr.err
+			if !errors.Is(err, io.EOF) {
+				tr.err = nil
+			}
+			return 0, err
+		}
+		if tr.in == nil {
+			tr.in = make([]byte, 4096)
+		}
+		nr, err := tr.r.Read(tr.in)
+		if nr < 0 || nr > len(tr.in) {
+			return 0, ErrInvalidRead
+		}
+		tr.out = tr.transform(tr.buf[:0], tr.in[:nr])
+		if errors.Is(err, io.EOF) {
+			tr.out = tr.flush(tr.out)
+		}
+		tr.err = err
+		tr.buf = tr.out[:0]
+	}
+	n := copy(p, tr.out)
+	tr.out = tr.out[n:]
+	return n, nil
+}
@@ -0,0 +1,75 @@
+package gonl
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestTransformReader(t *testing.T) {
+	errTimeout := errors.New("timeout")
+
+	// readAfterError reads from r, expecting errTimeout once, then
+	// reads the remainder of the stream.
+	readAfterError := func(t *testing.T, r io.Reader, before, after string) {
+		t.Helper()
+		got, err := ioutil.ReadAll(r)
+		if err != errTimeout {
+			t.Fatalf("GOT: %v; WANT: %v", err, errTimeout)
+		}
+		if string(got) != before {
+			t.Errorf("GOT: %q; WANT: %q", got, before)
+		}
+		got, err = ioutil.ReadAll(r)
+		ensureErrorNil(t, err)
+		if string(got) != after {
+			t.Errorf("GOT: %q; WANT: %q", got, after)
+		}
+	}
+
+	t.Run("LineEndingReader keeps pending CR", func(t *testing.T) {
+		r, err := NewLineEndingReader(&testReader{tuples: []tuple{
+			tuple{"abc\r", errTimeout},
+			tuple{"\ndef", io.EOF},
+		}}, CRToLF)
+		ensureErrorNil(t, err)
+		readAfterError(t, r, "abc", "\r\ndef")
+	})
+
+	t.Run("SqueezeReader keeps trailing blanks", func(t *testing.T) {
+		r, err := NewSqueezeReader(&testReader{tuples: []tuple{
+			tuple{"abc\n\n\n", errTimeout},
+			tuple{"\ndef\n", io.EOF},
+		}}, 1)
+		ensureErrorNil(t, err)
+		r.StripTrailing = true
+		readAfterError(t, r, "abc\n", "\ndef\n")
+	})
+
+	t.Run("BOMReader keeps odd byte", func(t *testing.T) {
+		r := NewBOMReader(&testReader{tuples: []tuple{
+			tuple{"\xff\xfea\x00b", errTimeout},
+			tuple{"\x00", io.EOF},
+		}})
+		readAfterError(t, r, "a", "b")
+	})
+
+	t.Run("error after EOF", func(t *testing.T) {
+		r, err := NewLineEndingReader(&testReader{tuples: []tuple{
+			tuple{"abc\r", io.EOF},
+		}}, CRToLF)
+		ensureErrorNil(t, err)
+		buf, err := ioutil.ReadAll(r)
+		ensureErrorNil(t, err)
+		if got, want := string(buf), "abc\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		// io.EOF continues to be returned without reading again.
+		n, err := r.Read(make([]byte, 4))
+		if n != 0 || err != io.EOF {
+			t.Errorf("GOT: %v, %v; WANT: 0, %v", n, err, io.EOF)
+		}
+	})
+}
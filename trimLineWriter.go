@@ -0,0 +1,118 @@
+package gonl
+
+import (
+	"bytes"
+	"io"
+)
+
+// TrimLineWriter is an io.WriteCloser that removes trailing spaces,
+// tabs, vertical tabs, and form feeds from each line written to it,
+// then writes the remaining bytes to the underlying io.WriteCloser.
+//
+// Rather than buffering entire lines, it writes each line up to its
+// final non-whitespace byte as soon as that byte is written, and only
+// holds a run of whitespace at the end of a Write until it learns
+// whether more non-whitespace bytes follow on the same line.
+//
+// It is important for caller to Close the TrimLineWriter to write any
+// bytes that remain buffered because of a previous write error.
+type TrimLineWriter struct {
+	// TrimCR, when true, also removes the CR of a CRLF line ending,
+	// along with any other CR in the trailing whitespace. When false,
+	// a CR immediately preceding a LF is preserved, while whitespace
+	// before it is removed. It must be set before the first Write.
+	TrimCR bool
+
+	wc   io.WriteCloser
+	held []byte // trailing whitespace of the current line
+	out  []byte // trimmed bytes not yet written
+}
+
+// NewTrimLineWriter returns a new TrimLineWriter that writes trimmed
+// lines to the provided io.WriteCloser.
+func NewTrimLineWriter(wc io.WriteCloser) *TrimLineWriter {
+	return &TrimLineWriter{wc: wc}
+}
+
+// Close discards trailing whitespace of a final line that is not
+// terminated with a newline, writes any bytes that remain buffered
+// because of a previous write error, then closes the underlying
+// io.WriteCloser. Invoking Close more than once has no effect and
+// returns nil.
+func (lw *TrimLineWriter) Close() error {
+	if lw.wc == nil {
+		return nil // already closed
+	}
+	var err error
+	if len(lw.out) > 0 {
+		_, err = writeAll(lw.wc, lw.out)
+	}
+	if cerr := lw.wc.Close(); err == nil {
+		err = cerr
+	}
+	lw.wc = nil
+	lw.held = nil
+	lw.out = nil
+	return err
+}
+
+// isTrimmable returns true when b is whitespace that may be trimmed
+// from the end of a line.
+func isTrimmable(b byte) bool {
+	switch b {
+	case ' ', '\t', '\v', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+// trim appends the trimmed bytes of segment, which does not contain a
+// newline, to lw.out, holding its trailing whitespace.
+func (lw *TrimLineWriter) trim(segment []byte) {
+	i := len(segment)
+	for i > 0 && isTrimmable(segment[i-1]) {
+		i--
+	}
+	if i > 0 {
+		// Held whitespace was followed by non-whitespace on this line.
+		lw.out = append(lw.out, lw.held...)
+		lw.out = append(lw.out, segment[:i]...)
+		lw.held = lw.held[:0]
+	}
+	lw.held = append(lw.held, segment[i:]...)
+}
+
+// Write removes trailing whitespace from each line in p, and writes
+// the remaining bytes to the underlying io.WriteCloser. Because the
+// bytes written no longer correspond to the bytes of p, when writing
+// fails, it returns len(p) along with the error, and the bytes not
+// written remain buffered, to be written by the next Write or by
+// Close. It returns ErrClosed after the TrimLineWriter has been
+// closed.
+func (lw *TrimLineWriter) Write(p []byte) (int, error) {
+	if lw.wc == nil {
+		return 0, ErrClosed
+	}
+
+	for rest := p; len(rest) > 0; {
+		index := bytes.IndexByte(rest, '\n')
+		if index == -1 {
+			lw.trim(rest)
+			break
+		}
+		lw.trim(rest[:index])
+		if !lw.TrimCR && len(lw.held) > 0 && lw.held[len(lw.held)-1] == '\r' {
+			lw.out = append(lw.out, '\r')
+		}
+		lw.out = append(lw.out, '\n')
+		lw.held = lw.held[:0]
+		rest = rest[index+1:]
+	}
+
+	if len(lw.out) == 0 {
+		return len(p), nil
+	}
+	nw, err := writeAll(lw.wc, lw.out)
+	lw.out = lw.out[:copy(lw.out, lw.out[nw:])]
+	return len(p), err
+}
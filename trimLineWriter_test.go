@@ -0,0 +1,69 @@
+package gonl
+
+import (
+	"io"
+	"testing"
+)
+
+func TestTrimLineWriter(t *testing.T) {
+	cases := []struct {
+		name   string
+		trimCR bool
+		input  string
+		want   string
+	}{
+		{"spaces and tabs", false, "a  \nb\t \t\n  c d \n", "a\nb\n  c d\n"},
+		{"blank lines", false, " \n\t\n\n", "\n\n\n"},
+		{"unterminated", false, "a\nb  ", "a\nb"},
+		{"keep CRLF", false, "a \r\nb\r\n", "a\r\nb\r\n"},
+		{"CR not at end", false, "a\r b\n", "a\r b\n"},
+		{"trim CRLF", true, "a \r\nb\r\n", "a\nb\n"},
+		{"trim CR in whitespace", true, "a\r \r\n", "a\n"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewTrimLineWriter(output)
+			lw.TrimCR = c.trimCR
+			ensureWrite(t, lw, c.input)
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, c.want)
+		})
+		t.Run(c.name+" one byte writes", func(t *testing.T) {
+			output := new(testBuffer)
+			lw := NewTrimLineWriter(output)
+			lw.TrimCR = c.trimCR
+			for i := 0; i < len(c.input); i++ {
+				ensureWrite(t, lw, c.input[i:i+1])
+			}
+			ensureErrorNil(t, lw.Close())
+			ensureStringer(t, output, c.want)
+		})
+	}
+
+	t.Run("holds trailing whitespace", func(t *testing.T) {
+		output := new(testBuffer)
+		lw := NewTrimLineWriter(output)
+
+		ensureWrite(t, lw, "a b  ")
+		ensureStringer(t, output, "a b")
+		ensureWrite(t, lw, " c")
+		ensureStringer(t, output, "a b   c")
+		ensureErrorNil(t, lw.Close())
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		output := &flakyWriteCloser{results: []flakyResult{{2, io.ErrShortWrite}}}
+		lw := NewTrimLineWriter(output)
+
+		n, err := lw.Write([]byte("line 1 \n"))
+		ensureError(t, err, io.ErrShortWrite.Error())
+		if got, want := n, 8; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureWrite(t, lw, "line 2\n")
+		ensureErrorNil(t, lw.Close())
+		ensureStringer(t, output, "line 1\nline 2\n")
+	})
+}
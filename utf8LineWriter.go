@@ -0,0 +1,151 @@
+package gonl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// UTF8Policy determines what a UTF8LineWriter does with a line that is
+// not valid UTF-8.
+type UTF8Policy int
+
+const (
+	// UTF8Replace replaces each run of invalid bytes with the Unicode
+	// replacement character, U+FFFD, then writes the line.
+	UTF8Replace UTF8Policy = iota
+
+	// UTF8Drop drops the line.
+	UTF8Drop
+
+	// UTF8Error drops the line, and causes Write to return an
+	// *InvalidUTF8Error along with the number of bytes from p up to
+	// and including the invalid line, so writing the remaining bytes
+	// of p continues with the following line.
+	UTF8Error
+)
+
+// errUTF8Stop is returned by validate to stop emitting lines after an
+// invalid line when using the UTF8Error policy.
+var errUTF8Stop = errors.New("stop after invalid UTF-8")
+
+// UTF8LineWriter is an io.WriteCloser that validates that each
+// completed line written to it is valid UTF-8, then writes it to the
+// underlying io.WriteCloser with a single Write call. Lines that are
+// not valid UTF-8 are handled according to its UTF8Policy.
+//
+// Because entire lines are validated, a multi-byte rune split across
+// separate Write calls is not mistaken for an invalid sequence.
+//
+// It is important for caller to Close the UTF8LineWriter to validate
+// and flush any residual data that was not terminated with a newline.
+type UTF8LineWriter struct {
+	lb     lineBuffer
+	wc     io.WriteCloser
+	policy UTF8Policy
+
+	offset  int64 // stream offset of the start of the next line
+	lines   int64 // number of lines validated
+	invalid *InvalidUTF8Error
+	fixed   []byte // repaired line
+}
+
+// NewUTF8LineWriter returns a new UTF8LineWriter that writes valid
+// lines to the provided io.WriteCloser, handling invalid lines
+// according to the specified policy.
+func NewUTF8LineWriter(wc io.WriteCloser, policy UTF8Policy) (*UTF8LineWriter, error) {
+	switch policy {
+	case UTF8Replace, UTF8Drop, UTF8Error:
+	default:
+		return nil, &ConfigError{Type: "UTF8LineWriter", Reason: fmt.Sprintf("with unknown policy: %d", policy)}
+	}
+	return &UTF8LineWriter{wc: wc, policy: policy}, nil
+}
+
+// Close validates and writes any buffered data that was not terminated
+// with a newline, then closes the underlying io.WriteCloser. A rune
+// that is incomplete at the end of the stream is invalid. Invoking
+// Close more than once has no effect and returns nil.
+func (lw *UTF8LineWriter) Close() error {
+	if lw.wc == nil {
+		return nil // already closed
+	}
+	err := lw.lb.flush(lw.validate)
+	if err == nil && lw.invalid != nil {
+		err = lw.invalid
+		lw.invalid = nil
+	}
+	if err != nil {
+		_ = lw.wc.Close()
+		lw.wc = nil
+		return err
+	}
+	err = lw.wc.Close()
+	lw.wc = nil
+	return err
+}
+
+// validate writes line to the underlying io.WriteCloser when it is
+// valid UTF-8, and otherwise handles it according to the policy.
+func (lw *UTF8LineWriter) validate(line []byte) error {
+	if lw.invalid != nil {
+		return errUTF8Stop
+	}
+
+	if i := invalidUTF8Index(line); i != -1 {
+		switch lw.policy {
+		case UTF8Replace:
+			lw.fixed = append(lw.fixed[:0], line[:i]...)
+			lw.fixed = append(lw.fixed, bytes.ToValidUTF8(line[i:], []byte(string(utf8.RuneError)))...)
+			if _, err := writeAll(lw.wc, lw.fixed); err != nil {
+				return err
+			}
+		case UTF8Error:
+			lw.invalid = &InvalidUTF8Error{Offset: lw.offset + int64(i), Line: lw.lines + 1}
+		}
+	} else if _, err := writeAll(lw.wc, line); err != nil {
+		return err
+	}
+
+	lw.lines++
+	lw.offset += int64(len(line))
+	return nil
+}
+
+// invalidUTF8Index returns the index of the first byte of the first
+// invalid sequence in p, or -1 when p is valid UTF-8.
+func invalidUTF8Index(p []byte) int {
+	if utf8.Valid(p) {
+		return -1
+	}
+	for i := 0; i < len(p); {
+		if p[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(p[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1 // not reached
+}
+
+// Write buffers p, and validates each completed line, writing it to
+// the underlying io.WriteCloser when it is valid UTF-8, and otherwise
+// handling it according to the policy. It returns ErrClosed after the
+// UTF8LineWriter has been closed.
+func (lw *UTF8LineWriter) Write(p []byte) (int, error) {
+	if lw.wc == nil {
+		return 0, ErrClosed
+	}
+	n, err := lw.lb.write(p, lw.validate)
+	if lw.invalid != nil && (err == nil || err == errUTF8Stop) {
+		err = lw.invalid
+		lw.invalid = nil
+	}
+	return n, err
+}
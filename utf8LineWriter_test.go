@@ -0,0 +1,86 @@
+package gonl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUTF8LineWriter(t *testing.T) {
+	t.Run("NewUTF8LineWriter", func(t *testing.T) {
+		_, err := NewUTF8LineWriter(new(discardWriteCloser), UTF8Policy(42))
+		ensureError(t, err, "cannot create UTF8LineWriter with unknown policy")
+	})
+
+	t.Run("rune split across writes", func(t *testing.T) {
+		output := new(testBuffer)
+		lw, err := NewUTF8LineWriter(output, UTF8Error)
+		ensureErrorNil(t, err)
+
+		euro := "€" // three bytes
+		ensureWrite(t, lw, "price: "+euro[:1])
+		ensureWrite(t, lw, euro[1:2])
+		ensureWrite(t, lw, euro[2:]+"5\n")
+		ensureErrorNil(t, lw.Close())
+		ensureStringer(t, output, "price: €5\n")
+	})
+
+	t.Run("UTF8Replace", func(t *testing.T) {
+		output := new(testBuffer)
+		lw, err := NewUTF8LineWriter(output, UTF8Replace)
+		ensureErrorNil(t, err)
+
+		ensureWrite(t, lw, "ok\nbad \xff\xfe here\n\xc3")
+		ensureErrorNil(t, lw.Close())
+		ensureStringer(t, output, "ok\nbad � here\n�")
+	})
+
+	t.Run("UTF8Drop", func(t *testing.T) {
+		output := new(testBuffer)
+		lw, err := NewUTF8LineWriter(output, UTF8Drop)
+		ensureErrorNil(t, err)
+
+		ensureWrite(t, lw, "line 1\nline \xff2\nline 3\n")
+		ensureErrorNil(t, lw.Close())
+		ensureStringer(t, output, "line 1\nline 3\n")
+	})
+
+	t.Run("UTF8Error", func(t *testing.T) {
+		output := new(testBuffer)
+		lw, err := NewUTF8LineWriter(output, UTF8Error)
+		ensureErrorNil(t, err)
+
+		ensureWrite(t, lw, "line 1\nli")
+		p := []byte("ne \xff2\nline 3\n")
+		n, err := lw.Write(p)
+		ensureError(t, err, "invalid UTF-8 at offset 12, line 2")
+
+		var ie *InvalidUTF8Error
+		if !errors.As(err, &ie) {
+			t.Fatalf("GOT: %T; WANT: %T", err, ie)
+		}
+		if got, want := ie.Line, int64(2); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := ie.Offset, int64(12); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// Returned count includes the invalid line, so the remaining
+		// bytes continue with the following line.
+		if got, want := n, 6; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureWrite(t, lw, string(p[n:]))
+
+		ensureErrorNil(t, lw.Close())
+		ensureStringer(t, output, "line 1\nline 3\n")
+	})
+
+	t.Run("UTF8Error at Close", func(t *testing.T) {
+		lw, err := NewUTF8LineWriter(new(testBuffer), UTF8Error)
+		ensureErrorNil(t, err)
+
+		ensureWrite(t, lw, "line 1\n\xe2\x82")
+		ensureError(t, lw.Close(), "invalid UTF-8 at offset 7, line 2")
+	})
+}
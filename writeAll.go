@@ -0,0 +1,34 @@
+package gonl
+
+import "io"
+
+// writeAll writes p to w, invoking Write again with the remaining
+// bytes after each short write that did not return an error. It
+// returns the number of bytes written, and either the error returned
+// by Write, or io.ErrShortWrite when Write made no progress without
+// returning an error.
+func writeAll(w io.Writer, p []byte) (int, error) {
+	nw, _, err := writeAllCalls(w, p)
+	return nw, err
+}
+
+// writeAllCalls is like writeAll, but also returns the number of
+// times Write was invoked.
+func writeAllCalls(w io.Writer, p []byte) (int, int, error) {
+	var nw, calls int
+	for nw < len(p) {
+		n, err := w.Write(p[nw:])
+		calls++
+		if n < 0 || n > len(p)-nw {
+			return nw, calls, ErrInvalidWrite
+		}
+		nw += n
+		if err != nil {
+			return nw, calls, err
+		}
+		if n == 0 {
+			return nw, calls, io.ErrShortWrite
+		}
+	}
+	return nw, calls, nil
+}
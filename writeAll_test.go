@@ -0,0 +1,52 @@
+package gonl
+
+import (
+	"io"
+	"testing"
+)
+
+// trickleWriter is an io.Writer that writes at most max bytes during
+// each Write call, returning a short count without an error when
+// given more than that.
+type trickleWriter struct {
+	io.Writer
+	max int
+}
+
+func (tw trickleWriter) Write(p []byte) (int, error) {
+	if len(p) > tw.max {
+		p = p[:tw.max]
+	}
+	return tw.Writer.Write(p)
+}
+
+func TestWriteAll(t *testing.T) {
+	t.Run("short writes without error", func(t *testing.T) {
+		output := new(testBuffer)
+		n, err := writeAll(trickleWriter{Writer: output, max: 3}, []byte("line 1\n"))
+		ensureErrorNil(t, err)
+		if got, want := n, 7; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStringer(t, output, "line 1\n")
+	})
+
+	t.Run("no progress", func(t *testing.T) {
+		output := new(testBuffer)
+		n, err := writeAll(trickleWriter{Writer: output, max: 0}, []byte("line 1\n"))
+		ensureError(t, err, io.ErrShortWrite.Error())
+		if got, want := n, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("short write with error", func(t *testing.T) {
+		output := new(testBuffer)
+		n, err := writeAll(ShortWriter(output, 4), []byte("line 1\n"))
+		ensureError(t, err, io.ErrShortWrite.Error())
+		if got, want := n, 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStringer(t, output, "line")
+	})
+}